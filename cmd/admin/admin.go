@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"hezzl/config"
+	"hezzl/internal/event"
+	"hezzl/internal/model"
+	"hezzl/internal/repository"
+	"hezzl/pkg/broker/nats"
+	"hezzl/pkg/db/postgres"
+	"hezzl/pkg/db/redis"
+	"hezzl/pkg/logger"
+	"log"
+	"math"
+	"os"
+	"time"
+)
+
+const (
+	commandTimer = time.Minute * 5
+	adminActor   = "admin-cli"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: admin -config <env file> <command> [flags]\n\ncommands:\n")
+	fmt.Fprintf(os.Stderr, "  compact-priorities -projectId <id>    renumber the goods of the project 1..N\n")
+	fmt.Fprintf(os.Stderr, "  dead-letters [-limit <n>]             list the goods events the events consumer gave up on\n")
+	fmt.Fprintf(os.Stderr, "  redrive-dead-letters [-seq <seq>]     publish the dead letter again, all of them without -seq\n")
+}
+
+func main() {
+	os.Exit(run())
+}
+
+// run executes the command and returns the exit code
+func run() int {
+	config.MustLoad()
+	conf := config.GetConfig()
+
+	logger.InitLog(logger.LogConfig{
+		Mode:     conf.Env,
+		LogPath:  conf.LogOutput,
+		LogLevel: conf.LogLevel,
+	})
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+
+	switch args[0] {
+	case "compact-priorities":
+		return compactPriorities(args[1:])
+	case "dead-letters":
+		return listDeadLetters(args[1:])
+	case "redrive-dead-letters":
+		return redriveDeadLetters(args[1:])
+	default:
+		usage()
+		return 2
+	}
+}
+
+// compactPriorities does the same as POST /project/compact-priorities
+func compactPriorities(args []string) int {
+	var projectId int
+
+	flags := flag.NewFlagSet("compact-priorities", flag.ExitOnError)
+	flags.IntVar(&projectId, "projectId", 0, "id of the project to compact")
+	if err := flags.Parse(args); err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	if projectId == 0 {
+		log.Print("the project id is not specified")
+		return 2
+	}
+
+	conf := config.GetConfig()
+
+	postgres, err := postgres.New(conf.StoragePath)
+	if err != nil {
+		log.Print("failed connect to postgres")
+		return 1
+	}
+	defer postgres.Close()
+
+	redis, err := redis.New(
+		conf.Redis.Host,
+		conf.Redis.Port,
+		conf.Redis.Password,
+		conf.Redis.TTLKeys,
+		conf.Redis.NumberDB,
+	)
+	if err != nil {
+		log.Print("failed connect to redis")
+		return 1
+	}
+	defer redis.Close()
+
+	projectsRepo := repository.NewProjectsRepo(&repository.ProjectsRepoDeps{
+		Logger:     logger.GetLogger(),
+		PostgresDB: postgres,
+	})
+
+	cacheRepo := repository.NewCacheRepo(&repository.CacheRepoDeps{
+		Logger:  logger.GetLogger(),
+		RedisDB: redis,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimer)
+	defer cancel()
+	ctx = model.ContextWithActor(ctx, adminActor)
+
+	changed, err := projectsRepo.CompactPriorities(ctx, projectId)
+	if err != nil {
+		log.Printf("failed to compact priorities: %s", err)
+		return 1
+	}
+
+	if len(changed) > 0 {
+		cacheRepo.InvalidateGoods()
+	}
+
+	log.Printf("priorities of project %d compacted, changed goods: %d", projectId, len(changed))
+	return 0
+}
+
+// listDeadLetters prints the dead letters with the reason and the event, the oldest first
+func listDeadLetters(args []string) int {
+	var limit int
+
+	flags := flag.NewFlagSet("dead-letters", flag.ExitOnError)
+	flags.IntVar(&limit, "limit", 20, "number of dead letters to list")
+	if err := flags.Parse(args); err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	if limit <= 0 {
+		log.Print("the limit must be positive")
+		return 2
+	}
+
+	conf := config.GetConfig()
+
+	nats, err := nats.New(conf.Nats.Host, conf.Nats.Port, conf.Nats.NameMess)
+	if err != nil {
+		log.Print("failed connect to nats")
+		return 1
+	}
+	defer nats.Close()
+
+	deadLetters := event.NewDeadLetters(&event.DeadLettersDeps{
+		Logger:     logger.GetLogger(),
+		NatsBroker: nats,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimer)
+	defer cancel()
+
+	list, err := deadLetters.List(ctx, limit)
+	if err != nil {
+		log.Printf("failed to list dead letters: %s", err)
+		return 1
+	}
+
+	for _, el := range list {
+		fmt.Printf("seq: %d, stored: %s, subject: %s, delivered: %d, reason: %s\n%s\n\n",
+			el.Seq, el.StoredAt.Format(time.RFC3339), el.Subject, el.Delivered, el.Reason, el.Data)
+	}
+
+	log.Printf("dead letters listed: %d", len(list))
+	return 0
+}
+
+// redriveDeadLetters publishes the dead letters again
+func redriveDeadLetters(args []string) int {
+	var seq uint64
+
+	flags := flag.NewFlagSet("redrive-dead-letters", flag.ExitOnError)
+	flags.Uint64Var(&seq, "seq", 0, "sequence of the dead letter to redrive, all of them when not specified")
+	if err := flags.Parse(args); err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	conf := config.GetConfig()
+
+	nats, err := nats.New(conf.Nats.Host, conf.Nats.Port, conf.Nats.NameMess)
+	if err != nil {
+		log.Print("failed connect to nats")
+		return 1
+	}
+	defer nats.Close()
+
+	deadLetters := event.NewDeadLetters(&event.DeadLettersDeps{
+		Logger:     logger.GetLogger(),
+		NatsBroker: nats,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimer)
+	defer cancel()
+
+	if seq != 0 {
+		if err := deadLetters.Redrive(ctx, seq); err != nil {
+			log.Printf("failed to redrive dead letter %d: %s", seq, err)
+			return 1
+		}
+		log.Printf("dead letter %d redriven", seq)
+		return 0
+	}
+
+	list, err := deadLetters.List(ctx, math.MaxInt)
+	if err != nil {
+		log.Printf("failed to list dead letters: %s", err)
+		return 1
+	}
+
+	for _, el := range list {
+		if err := deadLetters.Redrive(ctx, el.Seq); err != nil {
+			log.Printf("failed to redrive dead letter %d: %s", el.Seq, err)
+			return 1
+		}
+	}
+
+	log.Printf("dead letters redriven: %d", len(list))
+	return 0
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"hezzl/config"
 	"hezzl/internal/event"
 	"hezzl/internal/repository"
@@ -12,9 +12,19 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
+)
+
+const (
+	gracefulShutdownTimer = time.Second * 30
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run consumes the goods events until a signal and returns the exit code
+func run() (code int) {
 	config.MustLoad()
 	conf := config.GetConfig()
 
@@ -24,8 +34,12 @@ func main() {
 		LogLevel: conf.LogLevel,
 	})
 
+	if conf.Broker.Type != config.BrokerNats {
+		log.Printf("the events consumer reads nats, with the %s broker the app writes the events itself", conf.Broker.Type)
+		return 2
+	}
+
 	myLog := logger.GetLogger()
-	chErr := make(chan error, 1)
 
 	clickhouse, err := clickhouse.New(
 		conf.Clickhouse.Host,
@@ -35,13 +49,29 @@ func main() {
 		conf.Clickhouse.Password,
 	)
 	if err != nil {
-		log.Fatal("failed connect to clickhouse")
+		log.Print("failed connect to clickhouse")
+		return 1
 	}
+	defer func() {
+		if err := clickhouse.Close(); err != nil {
+			myLog.Error("failed to stop clickhouse", "error", err)
+			code = 1
+		}
+	}()
 
+	streamConfig := nats.StreamConfig(conf.Nats.Stream)
+	consumerConfig := nats.ConsumerConfig(conf.Nats.Consumer)
 	nats, err := nats.New(conf.Nats.Host, conf.Nats.Port, conf.Nats.NameMess)
 	if err != nil {
-		log.Fatal("failed connect to nats")
+		log.Print("failed connect to nats")
+		return 1
 	}
+	defer func() {
+		if err := nats.Close(); err != nil {
+			myLog.Error("failed to stop nats", "error", err)
+			code = 1
+		}
+	}()
 
 	logsRepo := repository.NewLogsRepo(&repository.LogsRepoDeps{
 		Logger:       logger.GetLogger(),
@@ -49,76 +79,71 @@ func main() {
 	})
 
 	loggingEvent := event.NewLogging(&event.LoggingDeps{
-		Logger:     logger.GetLogger(),
-		NatsBroker: nats,
-		ILogsRepo:  logsRepo,
+		Logger:    logger.GetLogger(),
+		Publisher: nats,
+		Subject:   conf.Nats.NameMess,
+		ILogsRepo: logsRepo,
 	})
 
-	consumer, err := loggingEvent.Broker.EnsureConsumer(
-		loggingEvent.Broker.NameMess,
-		loggingEvent.Broker.NameMess,
-		loggingEvent.Broker.NameMess,
+	if err := nats.CreateStream(conf.Nats.NameMess, conf.Nats.NameMess+".>", streamConfig); err != nil {
+		myLog.Error("failed to create stream", "error", err)
+		return 1
+	}
+
+	// dead letters are kept until they are redriven
+	deadLetterConfig := streamConfig
+	deadLetterConfig.Retention = "limits"
+	deadLetterConfig.MaxAge = 0
+
+	deadLetterStream := event.DeadLetterStream(conf.Nats.NameMess)
+	if err := nats.CreateStream(deadLetterStream, event.DeadLetterSubjects(conf.Nats.NameMess), deadLetterConfig); err != nil {
+		myLog.Error("failed to create dead-letter stream", "error", err)
+		return 1
+	}
+
+	subscriber, err := nats.EnsureConsumer(
+		conf.Nats.NameMess,
+		conf.Nats.NameMess,
+		conf.Nats.NameMess,
+		consumerConfig,
 	)
 	if err != nil {
-		chErr <- fmt.Errorf("failed to create pull subscriber: %w", err)
-		return
+		myLog.Error("failed to create pull subscriber", "error", err)
+		return 1
 	}
 
 	myLog.Info("subscribe successfully")
 
+	logConsumer := event.NewLogConsumer(&event.LogConsumerDeps{
+		Logger:        logger.GetLogger(),
+		Subscriber:    subscriber,
+		Logging:       loggingEvent,
+		BatchSize:     conf.Consumer.BatchSize,
+		FlushInterval: conf.Consumer.FlushInterval,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	consumerDone := make(chan struct{})
 	go func() {
-		for {
-			msgs, err := consumer.Fetch(5)
-			if err != nil {
-				myLog.Error("failed to fetch messages", "error", err)
-				continue
-			}
-
-			for msg := range msgs.Messages() {
-				if err := msgs.Error(); err != nil {
-					myLog.Warn("message read error", "error", err)
-					continue
-				}
-
-				loggingEvent.SendLogToDB(msg.Data())
-				if err := msg.Ack(); err != nil {
-					myLog.Error("failed to ack message", "error", err)
-				}
-			}
-		}
+		logConsumer.Run(ctx)
+		close(consumerDone)
 	}()
 
 	stop := make(chan os.Signal, 3)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
-	select {
-	case err := <-chErr:
-		myLog.Error("func main error", "error", err)
-		if err := nats.Close(); err != nil {
-			myLog.Error("failed to stop nats", "error", err)
-		}
-
-		if err := clickhouse.Close(); err != nil {
-			myLog.Error("failed to stop clickhouse", "error", err)
-		}
-
-		os.Exit(1)
-	case sig := <-stop:
-		myLog.Info("received signal, shutting down", "signal", sig)
-		var err error
+	sig := <-stop
+	myLog.Info("received signal, shutting down", "signal", sig)
 
-		if err = nats.Close(); err != nil {
-			myLog.Error("failed to stop nats", "error", err)
-		}
-
-		if err = clickhouse.Close(); err != nil {
-			myLog.Error("failed to stop clickhouse", "error", err)
-		}
-
-		if err != nil {
-			os.Exit(1)
-		}
-
-		myLog.Info("shutdown completed successfully")
+	// the consumer writes the pending batch before the connections are closed
+	cancel()
+	select {
+	case <-consumerDone:
+	case <-time.After(gracefulShutdownTimer):
+		myLog.Error("failed to stop consumer", "error", "consumer did not stop in time")
+		return 1
 	}
+
+	myLog.Info("consumer stopped, closing connections")
+	return 0
 }
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"hezzl/config"
+	"hezzl/internal/event"
+	"hezzl/internal/model"
+	"hezzl/internal/repository"
+	"hezzl/internal/service"
+	"hezzl/pkg/broker/nats"
+	"hezzl/pkg/db/clickhouse"
+	"hezzl/pkg/db/postgres"
+	"hezzl/pkg/logger"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const (
+	replayActor = "replay"
+
+	targetJetStream  = "jetstream"
+	targetClickhouse = "clickhouse"
+)
+
+// replay rebuilds the goods log from Postgres
+func main() {
+	os.Exit(run())
+}
+
+// run replays the events and returns the exit code, so the deferred closes are done before the exit
+func run() int {
+	var source, target string
+	var projectId, batchSize int
+	var dryRun bool
+	var filter model.ReplayFilter
+
+	flag.StringVar(&source, "source", model.ReplaySourceState, "what to replay: state (snapshots of the goods) or outbox (the stored events)")
+	flag.StringVar(&target, "target", targetClickhouse, "where to replay: clickhouse or jetstream")
+	flag.IntVar(&projectId, "projectId", 0, "id of the project to replay, every project when not specified")
+	flag.IntVar(&batchSize, "batch", 500, "number of events read and written at once")
+	flag.BoolVar(&dryRun, "dry-run", false, "read and count the events without writing them")
+	flag.Func("from", "replay the goods created or the events stored since the time, RFC 3339", timeFlag(&filter.From))
+	flag.Func("to", "replay the goods created or the events stored before the time, RFC 3339", timeFlag(&filter.To))
+
+	config.MustLoad()
+	conf := config.GetConfig()
+
+	logger.InitLog(logger.LogConfig{
+		Mode:     conf.Env,
+		LogPath:  conf.LogOutput,
+		LogLevel: conf.LogLevel,
+	})
+
+	filter.ProjectID = projectId
+
+	if target != targetClickhouse && target != targetJetStream {
+		log.Printf("unknown target %q", target)
+		return 2
+	}
+
+	if batchSize <= 0 {
+		log.Print("the batch size must be positive")
+		return 2
+	}
+
+	postgres, err := postgres.New(conf.StoragePath)
+	if err != nil {
+		log.Print("failed connect to postgres")
+		return 1
+	}
+	defer postgres.Close()
+
+	loggingDeps := event.LoggingDeps{
+		Logger:  logger.GetLogger(),
+		Subject: conf.Nats.NameMess,
+	}
+
+	if !dryRun {
+		switch target {
+		case targetJetStream:
+			nats, err := nats.New(conf.Nats.Host, conf.Nats.Port, conf.Nats.NameMess)
+			if err != nil {
+				log.Print("failed connect to nats")
+				return 1
+			}
+			defer nats.Close()
+
+			loggingDeps.Publisher = nats
+		case targetClickhouse:
+			clickhouse, err := clickhouse.New(
+				conf.Clickhouse.Host,
+				conf.Clickhouse.Port,
+				conf.Clickhouse.DB,
+				conf.Clickhouse.User,
+				conf.Clickhouse.Password,
+			)
+			if err != nil {
+				log.Print("failed connect to clickhouse")
+				return 1
+			}
+			defer clickhouse.Close()
+
+			loggingDeps.ILogsRepo = repository.NewLogsRepo(&repository.LogsRepoDeps{
+				Logger:       logger.GetLogger(),
+				ClickhouseDB: clickhouse,
+			})
+		}
+	}
+
+	loggingEvent := event.NewLogging(&loggingDeps)
+
+	sink := service.ReplaySinkFunc(loggingEvent.WriteLogs)
+	if target == targetJetStream {
+		sink = loggingEvent.PublishBatch
+	}
+
+	replay := service.NewReplay(&service.ReplayDeps{
+		Logger: logger.GetLogger(),
+		IReplayGoodsRepo: repository.NewGoodsRepo(&repository.GoodsRepoDeps{
+			Logger:     logger.GetLogger(),
+			PostgresDB: postgres,
+		}),
+		IReplayOutboxRepo: repository.NewOutboxRepo(&repository.OutboxRepoDeps{
+			Logger:     logger.GetLogger(),
+			PostgresDB: postgres,
+		}),
+		IReplaySink: sink,
+		BatchSize:   batchSize,
+		DryRun:      dryRun,
+	})
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	ctx = model.ContextWithActor(ctx, replayActor)
+
+	started := time.Now()
+	result, err := replay.Run(ctx, source, filter, func(progress model.ReplayProgress) {
+		log.Printf("replayed %d of %d events", progress.Done, progress.Total)
+	})
+	if err != nil {
+		log.Printf("replay failed after %d of %d events: %s", result.Done, result.Total, err)
+		return 1
+	}
+
+	if dryRun {
+		log.Printf("dry run: %d events of the %s source would be replayed to %s", result.Done, source, target)
+		return 0
+	}
+
+	log.Printf("replayed %d events of the %s source to %s in %s", result.Done, source, target, time.Since(started).Round(time.Millisecond))
+	return 0
+}
+
+func timeFlag(value **time.Time) func(string) error {
+	return func(s string) error {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return err
+		}
+
+		t = t.UTC()
+		*value = &t
+		return nil
+	}
+}
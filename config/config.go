@@ -1,7 +1,9 @@
 package config
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"time"
@@ -14,6 +16,9 @@ const (
 	ModeDev        = "dev"
 	ModeProd       = "prod"
 	defaultLogPath = "./logs/out.log"
+
+	BrokerNats   = "nats"
+	BrokerMemory = "memory"
 )
 
 var conf config
@@ -26,7 +31,12 @@ type config struct {
 	Redis       `env-prefix:"REDIS_"`
 	HttpServer  `env-prefix:"HTTP_"`
 	Clickhouse  `env-prefix:"CLICKHOUSE_"`
+	Broker      `env-prefix:"BROKER_"`
 	Nats        `env-prefix:"NATS_"`
+	Purge       `env-prefix:"PURGE_"`
+	Idempotency `env-prefix:"IDEMPOTENCY_"`
+	Outbox      `env-prefix:"OUTBOX_"`
+	Consumer    `env-prefix:"CONSUMER_"`
 }
 
 type HttpServer struct {
@@ -50,10 +60,70 @@ type Clickhouse struct {
 	Password string `env:"PASSWORD" env-required:"true"`
 }
 
+// Broker of goods events, nats or memory
+type Broker struct {
+	Type   string       `env:"TYPE" env-default:"nats"`
+	Memory MemoryBroker `env-prefix:"MEMORY_"`
+}
+
+// MemoryBroker is the in-memory broker for local runs and tests
+type MemoryBroker struct {
+	Size            int           `env:"SIZE" env-default:"10000"`
+	MaxDeliver      int           `env:"MAX_DELIVER" env-default:"10"`
+	DuplicateWindow time.Duration `env:"DUPLICATE_WINDOW" env-default:"10m"`
+}
+
+// Nats connection and subject of goods events
 type Nats struct {
-	Host     string `env:"HOST" env-required:"true"`
-	Port     string `env:"PORT" env-required:"true"`
-	NameMess string `env:"NAME_MESSAGES" env-required:"true"`
+	Host     string       `env:"HOST"`
+	Port     string       `env:"PORT"`
+	NameMess string       `env:"NAME_MESSAGES" env-default:"goods"`
+	Stream   NatsStream   `env-prefix:"STREAM_"`
+	Consumer NatsConsumer `env-prefix:"CONSUMER_"`
+}
+
+// NatsStream is the JetStream stream of goods events
+type NatsStream struct {
+	Retention       string        `env:"RETENTION" env-default:"limits"`
+	Storage         string        `env:"STORAGE" env-default:"file"`
+	MaxAge          time.Duration `env:"MAX_AGE" env-default:"168h"`
+	MaxMsgs         int64         `env:"MAX_MSGS" env-default:"-1"`
+	MaxBytes        int64         `env:"MAX_BYTES" env-default:"-1"`
+	DuplicateWindow time.Duration `env:"DUPLICATE_WINDOW" env-default:"10m"`
+	Replicas        int           `env:"REPLICAS" env-default:"1"`
+}
+
+// NatsConsumer is the JetStream consumer of goods events
+type NatsConsumer struct {
+	AckWait       time.Duration `env:"ACK_WAIT" env-default:"30s"`
+	MaxDeliver    int           `env:"MAX_DELIVER" env-default:"10"`
+	MaxAckPending int           `env:"MAX_ACK_PENDING" env-default:"1000"`
+}
+
+// Purge of removed goods
+type Purge struct {
+	Retention time.Duration `env:"RETENTION" env-default:"720h"`
+	Interval  time.Duration `env:"INTERVAL" env-default:"1h"`
+}
+
+// Idempotency keys of requests
+type Idempotency struct {
+	TTL     time.Duration `env:"TTL" env-default:"24h"`
+	LockTTL time.Duration `env:"LOCK_TTL" env-default:"1m"`
+}
+
+// Outbox of goods events
+type Outbox struct {
+	Interval   time.Duration `env:"INTERVAL" env-default:"1s"`
+	BatchSize  int           `env:"BATCH_SIZE" env-default:"100"`
+	Retention  time.Duration `env:"RETENTION" env-default:"168h"`
+	MaxBackoff time.Duration `env:"MAX_BACKOFF" env-default:"5m"`
+}
+
+// Consumer writing goods events to ClickHouse
+type Consumer struct {
+	BatchSize     int           `env:"BATCH_SIZE" env-default:"500"`
+	FlushInterval time.Duration `env:"FLUSH_INTERVAL" env-default:"1s"`
 }
 
 func MustLoad() {
@@ -70,9 +140,81 @@ func MustLoad() {
 		log.Fatalf("cannot read config: %s", err)
 	}
 
+	if err := conf.validate(); err != nil {
+		log.Fatalf("invalid config: %s", err)
+	}
+
 	log.Println("configuration file successfully loaded")
 }
 
+// validate checks the values the env tags can not
+func (c *config) validate() error {
+	var errs []error
+
+	if c.Purge.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("PURGE_INTERVAL must be positive, got %s", c.Purge.Interval))
+	}
+
+	if c.Outbox.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("OUTBOX_INTERVAL must be positive, got %s", c.Outbox.Interval))
+	}
+
+	if c.Outbox.BatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("OUTBOX_BATCH_SIZE must be positive, got %d", c.Outbox.BatchSize))
+	}
+
+	if c.Outbox.MaxBackoff <= 0 {
+		errs = append(errs, fmt.Errorf("OUTBOX_MAX_BACKOFF must be positive, got %s", c.Outbox.MaxBackoff))
+	}
+
+	if c.Consumer.BatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("CONSUMER_BATCH_SIZE must be positive, got %d", c.Consumer.BatchSize))
+	}
+
+	if c.Consumer.FlushInterval <= 0 {
+		errs = append(errs, fmt.Errorf("CONSUMER_FLUSH_INTERVAL must be positive, got %s", c.Consumer.FlushInterval))
+	}
+
+	retryAfter := c.Outbox.MaxBackoff + c.Outbox.Interval
+
+	switch c.Broker.Type {
+	case BrokerNats:
+		if c.Nats.Host == "" || c.Nats.Port == "" || c.Nats.NameMess == "" {
+			errs = append(errs, errors.New("NATS_HOST, NATS_PORT and NATS_NAME_MESSAGES are required with the nats broker"))
+		}
+
+		if c.Nats.Stream.DuplicateWindow < retryAfter {
+			errs = append(errs, fmt.Errorf("NATS_STREAM_DUPLICATE_WINDOW must be at least OUTBOX_MAX_BACKOFF + OUTBOX_INTERVAL (%s), got %s",
+				retryAfter, c.Nats.Stream.DuplicateWindow))
+		}
+	case BrokerMemory:
+		if c.Broker.Memory.Size <= 0 {
+			errs = append(errs, fmt.Errorf("BROKER_MEMORY_SIZE must be positive, got %d", c.Broker.Memory.Size))
+		}
+
+		if c.Broker.Memory.MaxDeliver < 0 {
+			errs = append(errs, fmt.Errorf("BROKER_MEMORY_MAX_DELIVER must not be negative, got %d", c.Broker.Memory.MaxDeliver))
+		}
+
+		if c.Broker.Memory.DuplicateWindow < retryAfter {
+			errs = append(errs, fmt.Errorf("BROKER_MEMORY_DUPLICATE_WINDOW must be at least OUTBOX_MAX_BACKOFF + OUTBOX_INTERVAL (%s), got %s",
+				retryAfter, c.Broker.Memory.DuplicateWindow))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("BROKER_TYPE must be %s or %s, got %q", BrokerNats, BrokerMemory, c.Broker.Type))
+	}
+
+	if c.Idempotency.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("IDEMPOTENCY_TTL must be positive, got %s", c.Idempotency.TTL))
+	}
+
+	if c.Idempotency.LockTTL <= 0 {
+		errs = append(errs, fmt.Errorf("IDEMPOTENCY_LOCK_TTL must be positive, got %s", c.Idempotency.LockTTL))
+	}
+
+	return errors.Join(errs...)
+}
+
 func GetConfig() *config {
 	return &conf
 }
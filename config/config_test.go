@@ -0,0 +1,133 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	valid := func() config {
+		var c config
+		c.Broker.Type = BrokerNats
+		c.Nats.Host = "nats"
+		c.Nats.Port = "4222"
+		c.Nats.NameMess = "goods"
+		c.Purge.Interval = time.Hour
+		c.Outbox.Interval = time.Second
+		c.Outbox.BatchSize = 100
+		c.Outbox.MaxBackoff = time.Minute * 5
+		c.Nats.Stream.DuplicateWindow = time.Minute * 10
+		c.Idempotency.TTL = time.Hour * 24
+		c.Idempotency.LockTTL = time.Minute
+		c.Consumer.BatchSize = 500
+		c.Consumer.FlushInterval = time.Second
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		change  func(c *config)
+		wantErr bool
+	}{
+		{
+			name:   "defaults",
+			change: func(c *config) {},
+		},
+		{
+			name:    "zero purge interval",
+			change:  func(c *config) { c.Purge.Interval = 0 },
+			wantErr: true,
+		},
+		{
+			name:    "negative outbox interval",
+			change:  func(c *config) { c.Outbox.Interval = -time.Second },
+			wantErr: true,
+		},
+		{
+			name:    "zero outbox batch size",
+			change:  func(c *config) { c.Outbox.BatchSize = 0 },
+			wantErr: true,
+		},
+		{
+			name:    "zero outbox max backoff",
+			change:  func(c *config) { c.Outbox.MaxBackoff = 0 },
+			wantErr: true,
+		},
+		{
+			name:    "zero consumer batch size",
+			change:  func(c *config) { c.Consumer.BatchSize = 0 },
+			wantErr: true,
+		},
+		{
+			name:    "negative consumer flush interval",
+			change:  func(c *config) { c.Consumer.FlushInterval = -time.Second },
+			wantErr: true,
+		},
+		{
+			name:    "duplicate window shorter than the outbox retries",
+			change:  func(c *config) { c.Nats.Stream.DuplicateWindow = time.Minute * 2 },
+			wantErr: true,
+		},
+		{
+			name:   "duplicate window equal to the outbox retries",
+			change: func(c *config) { c.Nats.Stream.DuplicateWindow = time.Minute*5 + time.Second },
+		},
+		{
+			name:    "unknown broker type",
+			change:  func(c *config) { c.Broker.Type = "kafka" },
+			wantErr: true,
+		},
+		{
+			name:    "nats broker without host",
+			change:  func(c *config) { c.Nats.Host = "" },
+			wantErr: true,
+		},
+		{
+			name: "memory broker without nats",
+			change: func(c *config) {
+				c.Broker.Type = BrokerMemory
+				c.Broker.Memory.Size = 10000
+				c.Broker.Memory.DuplicateWindow = time.Minute * 10
+				c.Nats = Nats{}
+			},
+		},
+		{
+			name: "zero memory broker size",
+			change: func(c *config) {
+				c.Broker.Type = BrokerMemory
+				c.Broker.Memory.DuplicateWindow = time.Minute * 10
+			},
+			wantErr: true,
+		},
+		{
+			name: "memory broker duplicate window shorter than the outbox retries",
+			change: func(c *config) {
+				c.Broker.Type = BrokerMemory
+				c.Broker.Memory.Size = 10000
+				c.Broker.Memory.DuplicateWindow = time.Minute
+			},
+			wantErr: true,
+		},
+		{
+			name:    "zero idempotency ttl",
+			change:  func(c *config) { c.Idempotency.TTL = 0 },
+			wantErr: true,
+		},
+		{
+			name:    "zero idempotency lock ttl",
+			change:  func(c *config) { c.Idempotency.LockTTL = 0 },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.change(&c)
+
+			if err := c.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
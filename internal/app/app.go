@@ -8,6 +8,8 @@ import (
 	"hezzl/internal/event"
 	"hezzl/internal/repository"
 	"hezzl/internal/service"
+	"hezzl/pkg/broker"
+	"hezzl/pkg/broker/memory"
 	"hezzl/pkg/broker/nats"
 	"hezzl/pkg/db/clickhouse"
 	"hezzl/pkg/db/postgres"
@@ -24,12 +26,18 @@ const (
 )
 
 type App struct {
-	logger     *slog.Logger
-	http       *http.Server
-	postgres   *postgres.PostgresDB
-	clickhouse *clickhouse.ClickhouseDB
-	redis      *redis.RedisDB
-	nats       *nats.NatsBroker
+	logger       *slog.Logger
+	http         *http.Server
+	goods        *service.Goods
+	outbox       *service.Outbox
+	logConsumer  interface{ Run(ctx context.Context) }
+	consumerDone chan struct{}
+	jobsCtx      context.Context
+	jobsCancel   context.CancelFunc
+	postgres     *postgres.PostgresDB
+	clickhouse   *clickhouse.ClickhouseDB
+	redis        *redis.RedisDB
+	broker       broker.Publisher
 }
 
 func New() *App {
@@ -62,9 +70,28 @@ func New() *App {
 		log.Fatal("failed connect to redis")
 	}
 
-	nats, err := nats.New(conf.Nats.Host, conf.Nats.Port, conf.Nats.NameMess)
-	if err != nil {
-		log.Fatal("failed connect to nats")
+	// the in-memory broker has no events consumer, so the app runs it
+	var publisher broker.Publisher
+	var subscriber broker.Subscriber
+
+	switch conf.Broker.Type {
+	case config.BrokerNats:
+		natsBroker, err := nats.New(conf.Nats.Host, conf.Nats.Port, conf.Nats.NameMess)
+		if err != nil {
+			log.Fatal("failed connect to nats")
+		}
+
+		if err := natsBroker.CreateStream(conf.Nats.NameMess, conf.Nats.NameMess+".>", nats.StreamConfig(conf.Nats.Stream)); err != nil {
+			log.Fatal(err)
+		}
+
+		publisher = natsBroker
+	case config.BrokerMemory:
+		memoryBroker := memory.New(conf.Broker.Memory.Size, conf.Broker.Memory.MaxDeliver, conf.Broker.Memory.DuplicateWindow)
+		subscriber = memoryBroker.Subscribe(conf.Nats.NameMess + ".>")
+		publisher = memoryBroker
+	default:
+		log.Fatalf("unknown broker type %q", conf.Broker.Type)
 	}
 
 	// Init repository
@@ -73,11 +100,29 @@ func New() *App {
 		PostgresDB: postgres,
 	})
 
+	projectsRepo := repository.NewProjectsRepo(&repository.ProjectsRepoDeps{
+		Logger:     logger.GetLogger(),
+		PostgresDB: postgres,
+	})
+
 	cacheRepo := repository.NewCacheRepo(&repository.CacheRepoDeps{
 		Logger:  logger.GetLogger(),
 		RedisDB: redis,
 	})
 
+	idempotencyRepo := repository.NewIdempotencyRepo(&repository.IdempotencyRepoDeps{
+		Logger:  logger.GetLogger(),
+		RedisDB: redis,
+		TTL:     conf.Idempotency.TTL,
+		LockTTL: conf.Idempotency.LockTTL,
+	})
+
+	outboxRepo := repository.NewOutboxRepo(&repository.OutboxRepoDeps{
+		Logger:     logger.GetLogger(),
+		PostgresDB: postgres,
+		MaxBackoff: conf.Outbox.MaxBackoff,
+	})
+
 	logsRepo := repository.NewLogsRepo(&repository.LogsRepoDeps{
 		Logger:       logger.GetLogger(),
 		ClickhouseDB: clickhouse,
@@ -85,21 +130,43 @@ func New() *App {
 
 	// Init event
 	eventLog := event.NewLogging(&event.LoggingDeps{
-		Logger:     logger.GetLogger(),
-		NatsBroker: nats,
-		ILogsRepo:  logsRepo,
+		Logger:    logger.GetLogger(),
+		Publisher: publisher,
+		Subject:   conf.Nats.NameMess,
+		ILogsRepo: logsRepo,
 	})
 
-	if err := eventLog.Broker.CreateStream(conf.Nats.NameMess, conf.Nats.NameMess); err != nil {
-		log.Fatal(err)
+	var logConsumer interface{ Run(ctx context.Context) }
+	if subscriber != nil {
+		logConsumer = event.NewLogConsumer(&event.LogConsumerDeps{
+			Logger:        logger.GetLogger(),
+			Subscriber:    subscriber,
+			Logging:       eventLog,
+			BatchSize:     conf.Consumer.BatchSize,
+			FlushInterval: conf.Consumer.FlushInterval,
+		})
 	}
 
 	// Init service
 	goodService := service.NewGoods(&service.GoodsDeps{
+		Logger:         logger.GetLogger(),
+		IGoodsRepo:     goodRepo,
+		ICacheRepo:     cacheRepo,
+		PurgeRetention: conf.Purge.Retention,
+	})
+
+	projectsService := service.NewProjects(&service.ProjectsDeps{
 		Logger:        logger.GetLogger(),
-		IGoodsRepo:    goodRepo,
+		IProjectsRepo: projectsRepo,
 		ICacheRepo:    cacheRepo,
-		IEventManager: eventLog,
+	})
+
+	outboxService := service.NewOutbox(&service.OutboxDeps{
+		Logger:           logger.GetLogger(),
+		IOutboxRepo:      outboxRepo,
+		IOutboxPublisher: eventLog,
+		BatchSize:        conf.Outbox.BatchSize,
+		Retention:        conf.Outbox.Retention,
 	})
 
 	// Init controllers
@@ -112,8 +179,20 @@ func New() *App {
 		IGoodsService:  goodService,
 	})
 
+	projectsController := controller.NewProjects(&controller.ProjectsDeps{
+		BaseController:   baseController,
+		IProjectsService: projectsService,
+	})
+
+	idempotencyController := controller.NewIdempotency(&controller.IdempotencyDeps{
+		BaseController:   baseController,
+		IIdempotencyRepo: idempotencyRepo,
+	})
+
 	handler := NewActiveHandlers(&activeHandlersDeps{
-		Goods: goodsController,
+		Goods:       goodsController,
+		Projects:    projectsController,
+		Idempotency: idempotencyController,
 	})
 
 	// Init server
@@ -122,17 +201,38 @@ func New() *App {
 		Handler: handler.InitRouters(),
 	}
 
+	// Background jobs live until the app is stopped
+	jobsCtx, jobsCancel := context.WithCancel(context.Background())
+
 	return &App{
-		logger:     logger.GetLogger(),
-		http:       server,
-		postgres:   postgres,
-		clickhouse: clickhouse,
-		redis:      redis,
-		nats:       nats,
+		logger:       logger.GetLogger(),
+		http:         server,
+		goods:        goodService,
+		outbox:       outboxService,
+		logConsumer:  logConsumer,
+		consumerDone: make(chan struct{}),
+		jobsCtx:      jobsCtx,
+		jobsCancel:   jobsCancel,
+		postgres:     postgres,
+		clickhouse:   clickhouse,
+		redis:        redis,
+		broker:       publisher,
 	}
 }
 
 func (a *App) Start() error {
+	go a.goods.RunPurge(a.jobsCtx, config.GetConfig().Purge.Interval)
+	go a.outbox.RunRelay(a.jobsCtx, config.GetConfig().Outbox.Interval)
+
+	if a.logConsumer != nil {
+		go func() {
+			a.logConsumer.Run(a.jobsCtx)
+			close(a.consumerDone)
+		}()
+	} else {
+		close(a.consumerDone)
+	}
+
 	a.logger.Info("app: successfully started", "port", config.GetConfig().HttpServer.Port)
 	if err := a.http.ListenAndServe(); err != nil {
 		return err
@@ -147,13 +247,22 @@ func (a *App) Stop() error {
 	ctx, cancel := context.WithTimeout(context.Background(), gracefulShutdownTimer)
 	defer cancel()
 
+	a.jobsCancel()
+
 	if err := a.http.Shutdown(ctx); err != nil {
 		a.logger.Error("failed to stop http server", "error", err)
 		return err
 	}
 
-	if err := a.nats.Close(); err != nil {
-		a.logger.Error("failed to stop nats", "error", err)
+	// the consumer writes the pending batch to clickhouse before it is closed
+	select {
+	case <-a.consumerDone:
+	case <-ctx.Done():
+		a.logger.Error("failed to stop events consumer", "error", ctx.Err())
+	}
+
+	if err := a.broker.Close(); err != nil {
+		a.logger.Error("failed to stop broker", "error", err)
 		return err
 	}
 
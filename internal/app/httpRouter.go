@@ -9,15 +9,21 @@ import (
 
 type activeHandlers struct {
 	*controller.Goods
+	*controller.Projects
+	*controller.Idempotency
 }
 
 type activeHandlersDeps struct {
 	*controller.Goods
+	*controller.Projects
+	*controller.Idempotency
 }
 
 func NewActiveHandlers(deps *activeHandlersDeps) *activeHandlers {
 	return &activeHandlers{
-		Goods: deps.Goods,
+		Goods:       deps.Goods,
+		Projects:    deps.Projects,
+		Idempotency: deps.Idempotency,
 	}
 }
 
@@ -26,23 +32,96 @@ func (h *activeHandlers) InitRouters() *http.ServeMux {
 
 	engine.Handle("POST /good/create", middleware.ChainMiddleware(
 		middleware.HandlerLog(logger.GetLogger()),
+		controller.Actor(),
+		h.Idempotency.Middleware(),
 	)(h.Goods.Create()))
 
+	engine.Handle("POST /goods/bulk-create", middleware.ChainMiddleware(
+		middleware.HandlerLog(logger.GetLogger()),
+		controller.Actor(),
+		h.Idempotency.Middleware(),
+	)(h.Goods.BulkCreate()))
+
 	engine.Handle("PATCH /good/update", middleware.ChainMiddleware(
 		middleware.HandlerLog(logger.GetLogger()),
+		controller.Actor(),
+		h.Idempotency.Middleware(),
 	)(h.Goods.Update()))
 
 	engine.Handle("DELETE /good/remove", middleware.ChainMiddleware(
 		middleware.HandlerLog(logger.GetLogger()),
+		controller.Actor(),
+		h.Idempotency.Middleware(),
 	)(h.Goods.Remove()))
 
+	engine.Handle("PATCH /goods/bulk-update", middleware.ChainMiddleware(
+		middleware.HandlerLog(logger.GetLogger()),
+		controller.Actor(),
+	)(h.Goods.BulkUpdate()))
+
+	engine.Handle("DELETE /goods/bulk-remove", middleware.ChainMiddleware(
+		middleware.HandlerLog(logger.GetLogger()),
+		controller.Actor(),
+	)(h.Goods.BulkRemove()))
+
+	engine.Handle("PATCH /good/restore", middleware.ChainMiddleware(
+		middleware.HandlerLog(logger.GetLogger()),
+		controller.Actor(),
+	)(h.Goods.Restore()))
+
+	engine.Handle("DELETE /goods/purge", middleware.ChainMiddleware(
+		middleware.HandlerLog(logger.GetLogger()),
+		controller.Actor(),
+	)(h.Goods.Purge()))
+
+	engine.Handle("GET /good/get", middleware.ChainMiddleware(
+		middleware.HandlerLog(logger.GetLogger()),
+	)(h.Goods.Get()))
+
 	engine.Handle("GET /goods/list", middleware.ChainMiddleware(
 		middleware.HandlerLog(logger.GetLogger()),
 	)(h.Goods.List()))
 
 	engine.Handle("PATCH /good/reprioritizy", middleware.ChainMiddleware(
 		middleware.HandlerLog(logger.GetLogger()),
+		controller.Actor(),
+		h.Idempotency.Middleware(),
 	)(h.Goods.Reprioritizy()))
 
+	engine.Handle("GET /goods/priorities/check", middleware.ChainMiddleware(
+		middleware.HandlerLog(logger.GetLogger()),
+	)(h.Goods.CheckPriorities()))
+
+	engine.Handle("POST /goods/priorities/repair", middleware.ChainMiddleware(
+		middleware.HandlerLog(logger.GetLogger()),
+		controller.Actor(),
+	)(h.Goods.RepairPriorities()))
+
+	engine.Handle("POST /project/create", middleware.ChainMiddleware(
+		middleware.HandlerLog(logger.GetLogger()),
+	)(h.Projects.Create()))
+
+	engine.Handle("PATCH /project/update", middleware.ChainMiddleware(
+		middleware.HandlerLog(logger.GetLogger()),
+	)(h.Projects.Update()))
+
+	engine.Handle("DELETE /project/remove", middleware.ChainMiddleware(
+		middleware.HandlerLog(logger.GetLogger()),
+		controller.Actor(),
+	)(h.Projects.Remove()))
+
+	engine.Handle("POST /project/compact-priorities", middleware.ChainMiddleware(
+		middleware.HandlerLog(logger.GetLogger()),
+		controller.Actor(),
+	)(h.Projects.CompactPriorities()))
+
+	engine.Handle("GET /projects/list", middleware.ChainMiddleware(
+		middleware.HandlerLog(logger.GetLogger()),
+	)(h.Projects.List()))
+
+	engine.Handle("GET /project/get", middleware.ChainMiddleware(
+		middleware.HandlerLog(logger.GetLogger()),
+	)(h.Projects.Get()))
+
 	return engine
 }
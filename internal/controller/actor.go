@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"hezzl/internal/model"
+	"net/http"
+	"strings"
+)
+
+const (
+	actorHeader    = "X-Actor"
+	actorMaxLength = 255
+)
+
+// Actor takes the actor of the changes from the X-Actor header
+func Actor() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			actor := strings.TrimSpace(r.Header.Get(actorHeader))
+			if actor == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(actor) > actorMaxLength {
+				actor = actor[:actorMaxLength]
+			}
+
+			next.ServeHTTP(w, r.WithContext(model.ContextWithActor(r.Context(), actor)))
+		})
+	}
+}
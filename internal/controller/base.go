@@ -4,10 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"hezzl/internal/model"
 	"log/slog"
+	"mime"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Universal structure for sending responses
@@ -56,55 +61,126 @@ func (b *BaseController) GetIntQueryParam(r *http.Request, name string) (int, er
 	return paramInt, nil
 }
 
-func (b *BaseController) SendJsonError(w http.ResponseWriter, mess string, err error) {
+func (b *BaseController) GetBoolQueryParam(r *http.Request, name string) (bool, error) {
+	paramStr := r.URL.Query().Get(name)
+
+	paramBool, err := strconv.ParseBool(paramStr)
+	if err != nil {
+		return false, err
+	}
+
+	return paramBool, nil
+}
+
+// GetTimeQueryParam accepts an RFC 3339 timestamp or a 2006-01-02 date
+func (b *BaseController) GetTimeQueryParam(r *http.Request, name string) (paramTime time.Time, dateOnly bool, err error) {
+	paramStr := r.URL.Query().Get(name)
+
+	if paramTime, err := time.Parse(time.DateOnly, paramStr); err == nil {
+		return paramTime, true, nil
+	}
+
+	paramTime, err = time.Parse(time.RFC3339, paramStr)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	return paramTime, false, nil
+}
+
+// CheckContentType accepts a request body without a content type or with one of the types
+func (b *BaseController) CheckContentType(r *http.Request, types ...string) error {
+	header := r.Header.Get("Content-Type")
+	if header == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil || !slices.Contains(types, mediaType) {
+		return fmt.Errorf("%w %q, expected one of %s", model.ErrMediaType, header, strings.Join(types, ", "))
+	}
+
+	return nil
+}
+
+// SetETag sets the ETag header, it has to be called before the response is written
+func (b *BaseController) SetETag(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", etag)
+}
+
+// GetIfMatchVersion reads the expected version of a good from the If-Match header
+func (b *BaseController) GetIfMatchVersion(r *http.Request) (*int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid If-Match header %q", model.ErrValidate, header)
+	}
+
+	return &version, nil
+}
+
+// ErrorStatus maps an error returned by the services to the HTTP status of the response
+func ErrorStatus(err error) int {
 	switch {
-	case errors.Is(err, model.ErrCurrentPriority):
-		b.Log.Warn(mess, "error", err)
-		b.SendJsonResp(w, 400, &BaseControllerResponce{
-			Status:  http.StatusBadRequest,
-			Message: mess,
-			Error:   err.Error(),
-		})
-	case errors.Is(err, model.ErrMaxPriority):
-		b.Log.Warn(mess, "error", err)
-		b.SendJsonResp(w, 400, &BaseControllerResponce{
-			Status:  http.StatusBadRequest,
-			Message: mess,
-			Error:   err.Error(),
-		})
-	case errors.Is(err, model.ErrValidate):
-		b.Log.Warn(mess, "error", err)
-		b.SendJsonResp(w, 400, &BaseControllerResponce{
-			Status:  http.StatusBadRequest,
-			Message: mess,
-			Error:   err.Error(),
-		})
-	case errors.Is(err, model.ErrQueryParam):
-		b.Log.Warn(mess, "error", err)
-		b.SendJsonResp(w, 400, &BaseControllerResponce{
-			Status:  http.StatusBadRequest,
-			Message: mess,
-			Error:   err.Error(),
-		})
+	case errors.Is(err, model.ErrCurrentPriority),
+		errors.Is(err, model.ErrMaxPriority),
+		errors.Is(err, model.ErrValidate),
+		errors.Is(err, model.ErrQueryParam),
+		errors.Is(err, model.ErrAnchorNotFound),
+		errors.Is(err, model.ErrAnchorOtherProject),
+		errors.Is(err, model.ErrAnchorRemoved):
+		return http.StatusBadRequest
 	case errors.Is(err, model.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, model.ErrConflict),
+		errors.Is(err, model.ErrNotRemoved),
+		errors.Is(err, model.ErrIdempotencyInProgress):
+		return http.StatusConflict
+	case errors.Is(err, model.ErrIdempotencyMismatch):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, model.ErrMediaType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, model.ErrBulkAborted):
+		return http.StatusFailedDependency
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (b *BaseController) SendJsonError(w http.ResponseWriter, mess string, err error) {
+	switch status := ErrorStatus(err); status {
+	case http.StatusNotFound:
 		b.Log.Warn(mess, "error", err)
-		b.SendJsonResp(w, 404, &model.Custom404{
+		b.SendJsonResp(w, status, &model.Custom404{
 			Message: "errors.common.notFound",
 			Code:    3,
 		})
-	case errors.Is(err, context.DeadlineExceeded):
+	case http.StatusGatewayTimeout:
 		b.Log.Error("request processing exceeded the allowed time limit", "error", err)
-		b.SendJsonResp(w, 504, &BaseControllerResponce{
-			Status:  http.StatusGatewayTimeout,
+		b.SendJsonResp(w, status, &BaseControllerResponce{
+			Status:  status,
 			Message: "request processing exceeded the allowed time limit",
 			Error:   err.Error(),
 		})
-	default:
+	case http.StatusInternalServerError:
 		b.Log.Error("internal server error", "error", err)
-		b.SendJsonResp(w, 500, &BaseControllerResponce{
-			Status:  http.StatusInternalServerError,
+		b.SendJsonResp(w, status, &BaseControllerResponce{
+			Status:  status,
 			Message: "internal server error",
 			Error:   err.Error(),
 		})
+	default:
+		b.Log.Warn(mess, "error", err)
+		b.SendJsonResp(w, status, &BaseControllerResponce{
+			Status:  status,
+			Message: mess,
+			Error:   err.Error(),
+		})
 	}
 }
@@ -3,17 +3,35 @@ package controller
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"hash/fnv"
 	"hezzl/internal/model"
 	"hezzl/pkg/validate"
 	"net/http"
 )
 
+const (
+	bulkMaxItems = 10000
+	listMaxLimit = 1000
+
+	contentTypeJson       = "application/json"
+	contentTypeMergePatch = "application/merge-patch+json"
+)
+
 type IGoodsService interface {
 	Create(ctx context.Context, data model.ProductCreateRequest) (*model.Product, error)
+	BulkCreate(ctx context.Context, projectId int, data []model.ProductCreateRequest) ([]model.Product, error)
 	Update(ctx context.Context, data model.ProductUpdateRequest) (*model.Product, error)
-	Remove(ctx context.Context, id, projectId int) (*model.ProductRemoveResponce, error)
-	List(ctx context.Context, offset, limit int) (*model.ProductListResponce, error)
+	Remove(ctx context.Context, data model.ProductRemoveRequest) (*model.ProductRemoveResponce, error)
+	BulkUpdate(ctx context.Context, data []model.ProductUpdateRequest, atomic bool) ([]model.ProductBulkOutcome, error)
+	BulkRemove(ctx context.Context, data []model.ProductRemoveRequest, atomic bool) ([]model.ProductBulkOutcome, error)
+	Restore(ctx context.Context, data model.ProductRestoreRequest) (*model.Product, error)
+	Purge(ctx context.Context, projectId int) (*model.ProductPurgeResponce, error)
+	Get(ctx context.Context, id, projectId int, includeRemoved bool) (*model.Product, error)
+	List(ctx context.Context, filter model.ProductListFilter) (*model.ProductListResponce, error)
 	Reprioritizy(ctx context.Context, data model.ProductReprioritizyRequest) (*model.ProductReprioritizyResponce, error)
+	CheckPriorities(ctx context.Context, projectId int) (*model.PriorityCheckResponce, error)
+	RepairPriorities(ctx context.Context, projectId int) (*model.ProductReprioritizyResponce, error)
 }
 
 type Goods struct {
@@ -65,6 +83,72 @@ func (g *Goods) Create() http.HandlerFunc {
 	}
 }
 
+// BulkCreate creates the valid items in one transaction
+func (g *Goods) BulkCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := g.base.GetIntQueryParam(r, "projectId")
+		if err != nil {
+			g.base.SendJsonError(w, err.Error(), model.ErrQueryParam)
+			return
+		}
+
+		var reqData []model.ProductCreateRequest
+
+		if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+			g.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
+		if len(reqData) == 0 || len(reqData) > bulkMaxItems {
+			g.base.SendJsonError(w, fmt.Sprintf("the number of items must be from 1 to %d", bulkMaxItems), model.ErrValidate)
+			return
+		}
+
+		resp := model.ProductBulkResponce{
+			Results: make([]model.ProductBulkResult, len(reqData)),
+		}
+
+		valid := make([]model.ProductCreateRequest, 0, len(reqData))
+		validIndexes := make([]int, 0, len(reqData))
+
+		for i := range reqData {
+			reqData[i].ProjectID = projectId
+			resp.Results[i].Index = i
+
+			if err := validate.IsValid(reqData[i]); err != nil {
+				resp.Results[i].Status = http.StatusBadRequest
+				resp.Results[i].Error = err.Error()
+				resp.Failed++
+				continue
+			}
+
+			valid = append(valid, reqData[i])
+			validIndexes = append(validIndexes, i)
+		}
+
+		if len(valid) > 0 {
+			created, err := g.service.BulkCreate(r.Context(), projectId, valid)
+			if err != nil {
+				g.base.SendJsonError(w, err.Error(), err)
+				return
+			}
+
+			for i, index := range validIndexes {
+				resp.Results[index].Status = http.StatusCreated
+				resp.Results[index].Product = &created[i]
+				resp.Succeeded++
+			}
+		}
+
+		status := http.StatusCreated
+		if resp.Failed > 0 {
+			status = http.StatusMultiStatus
+		}
+
+		g.base.SendJsonResp(w, status, resp)
+	}
+}
+
 func (g *Goods) Update() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id, err := g.base.GetIntQueryParam(r, "id")
@@ -79,6 +163,11 @@ func (g *Goods) Update() http.HandlerFunc {
 			return
 		}
 
+		if err := g.base.CheckContentType(r, contentTypeJson, contentTypeMergePatch); err != nil {
+			g.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
 		reqData := model.ProductUpdateRequest{
 			ID:        id,
 			ProjectID: projectId,
@@ -89,6 +178,16 @@ func (g *Goods) Update() http.HandlerFunc {
 			return
 		}
 
+		if err := reqData.Check(); err != nil {
+			g.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
+		if reqData.Version, err = g.expectedVersion(r, reqData.Version); err != nil {
+			g.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
 		if err := validate.IsValid(reqData); err != nil {
 			g.base.SendJsonError(w, err.Error(), model.ErrValidate)
 			return
@@ -100,6 +199,7 @@ func (g *Goods) Update() http.HandlerFunc {
 			return
 		}
 
+		g.base.SetETag(w, goodETag(resp.Version))
 		g.base.SendJsonResp(w, 200, resp)
 	}
 }
@@ -118,57 +218,401 @@ func (g *Goods) Remove() http.HandlerFunc {
 			return
 		}
 
-		resp, err := g.service.Remove(r.Context(), id, projectId)
+		reqData := model.ProductRemoveRequest{
+			ID:        id,
+			ProjectID: projectId,
+		}
+
+		if r.URL.Query().Get("version") != "" {
+			version, err := g.base.GetIntQueryParam(r, "version")
+			if err != nil {
+				g.base.SendJsonError(w, err.Error(), model.ErrQueryParam)
+				return
+			}
+			reqData.Version = &version
+		}
+
+		if reqData.Version, err = g.expectedVersion(r, reqData.Version); err != nil {
+			g.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
+		resp, err := g.service.Remove(r.Context(), reqData)
 		if err != nil {
 			g.base.SendJsonError(w, err.Error(), err)
 			return
 		}
 
+		g.base.SetETag(w, goodETag(resp.Version))
 		g.base.SendJsonResp(w, 200, resp)
 	}
 }
 
-func (g *Goods) List() http.HandlerFunc {
+// BulkUpdate applies every item or none of them with atomic=true
+func (g *Goods) BulkUpdate() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		offsetStr := r.URL.Query().Get("offset")
-		limitStr := r.URL.Query().Get("limit")
+		var reqData []model.ProductUpdateRequest
 
-		var offset, limit int
+		if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+			g.base.SendJsonError(w, err.Error(), err)
+			return
+		}
 
-		switch offsetStr {
-		case "":
-			offset = 1
-		default:
-			var err error
-			offset, err = g.base.GetIntQueryParam(r, "offset")
+		bulkRun(g, w, r, reqData, g.service.BulkUpdate)
+	}
+}
+
+// BulkRemove removes every item or none of them with atomic=true
+func (g *Goods) BulkRemove() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var reqData []model.ProductRemoveRequest
+
+		if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+			g.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
+		bulkRun(g, w, r, reqData, g.service.BulkRemove)
+	}
+}
+
+// bulkChecker is implemented by bulk items with checks the validate tags can not express
+type bulkChecker interface {
+	Check() error
+}
+
+// bulkRun runs the valid items and reports the status of every item
+func bulkRun[T any](
+	g *Goods,
+	w http.ResponseWriter,
+	r *http.Request,
+	reqData []T,
+	run func(ctx context.Context, data []T, atomic bool) ([]model.ProductBulkOutcome, error),
+) {
+	var atomic bool
+	if r.URL.Query().Get("atomic") != "" {
+		var err error
+		atomic, err = g.base.GetBoolQueryParam(r, "atomic")
+		if err != nil {
+			g.base.SendJsonError(w, err.Error(), model.ErrQueryParam)
+			return
+		}
+	}
+
+	if len(reqData) == 0 || len(reqData) > bulkMaxItems {
+		g.base.SendJsonError(w, fmt.Sprintf("the number of items must be from 1 to %d", bulkMaxItems), model.ErrValidate)
+		return
+	}
+
+	outcomes := make([]model.ProductBulkOutcome, len(reqData))
+	valid := make([]T, 0, len(reqData))
+	validIndexes := make([]int, 0, len(reqData))
+
+	for i := range reqData {
+		if err := validate.IsValid(reqData[i]); err != nil {
+			outcomes[i].Err = fmt.Errorf("%w: %w", model.ErrValidate, err)
+			continue
+		}
+
+		if item, ok := any(&reqData[i]).(bulkChecker); ok {
+			if err := item.Check(); err != nil {
+				outcomes[i].Err = err
+				continue
+			}
+		}
+
+		valid = append(valid, reqData[i])
+		validIndexes = append(validIndexes, i)
+	}
+
+	switch {
+	case atomic && len(valid) < len(reqData):
+		for _, index := range validIndexes {
+			outcomes[index].Err = model.ErrBulkAborted
+		}
+	case len(valid) > 0:
+		result, err := run(r.Context(), valid, atomic)
+		if err != nil {
+			g.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
+		for i, index := range validIndexes {
+			outcomes[index] = result[i]
+		}
+	}
+
+	resp := model.ProductBulkResponce{
+		Results: make([]model.ProductBulkResult, 0, len(outcomes)),
+	}
+
+	for i, el := range outcomes {
+		item := model.ProductBulkResult{
+			Index:   i,
+			Status:  http.StatusOK,
+			Product: el.Product,
+		}
+
+		if el.Err != nil {
+			item.Status = ErrorStatus(el.Err)
+			item.Error = el.Err.Error()
+			resp.Failed++
+		} else {
+			resp.Succeeded++
+		}
+
+		resp.Results = append(resp.Results, item)
+	}
+
+	status := http.StatusOK
+	if resp.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+
+	g.base.SendJsonResp(w, status, resp)
+}
+
+func (g *Goods) Restore() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := g.base.GetIntQueryParam(r, "id")
+		if err != nil {
+			g.base.SendJsonError(w, err.Error(), model.ErrQueryParam)
+			return
+		}
+
+		projectId, err := g.base.GetIntQueryParam(r, "projectId")
+		if err != nil {
+			g.base.SendJsonError(w, err.Error(), model.ErrQueryParam)
+			return
+		}
+
+		reqData := model.ProductRestoreRequest{
+			ID:        id,
+			ProjectID: projectId,
+		}
+
+		if r.URL.Query().Get("version") != "" {
+			version, err := g.base.GetIntQueryParam(r, "version")
 			if err != nil {
 				g.base.SendJsonError(w, err.Error(), model.ErrQueryParam)
 				return
 			}
+			reqData.Version = &version
+		}
+
+		if reqData.Version, err = g.expectedVersion(r, reqData.Version); err != nil {
+			g.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
+		resp, err := g.service.Restore(r.Context(), reqData)
+		if err != nil {
+			g.base.SendJsonError(w, err.Error(), err)
+			return
 		}
 
-		switch limitStr {
-		case "":
-			limit = 10
-		default:
+		g.base.SetETag(w, goodETag(resp.Version))
+		g.base.SendJsonResp(w, 200, resp)
+	}
+}
+
+func (g *Goods) Purge() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var projectId int
+
+		if r.URL.Query().Get("projectId") != "" {
 			var err error
-			limit, err = g.base.GetIntQueryParam(r, "limit")
+			projectId, err = g.base.GetIntQueryParam(r, "projectId")
+			if err != nil {
+				g.base.SendJsonError(w, err.Error(), model.ErrQueryParam)
+				return
+			}
+		}
+
+		resp, err := g.service.Purge(r.Context(), projectId)
+		if err != nil {
+			g.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
+		g.base.SendJsonResp(w, 200, resp)
+	}
+}
+
+func (g *Goods) Get() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := g.base.GetIntQueryParam(r, "id")
+		if err != nil {
+			g.base.SendJsonError(w, err.Error(), model.ErrQueryParam)
+			return
+		}
+
+		projectId, err := g.base.GetIntQueryParam(r, "projectId")
+		if err != nil {
+			g.base.SendJsonError(w, err.Error(), model.ErrQueryParam)
+			return
+		}
+
+		var includeRemoved bool
+		if r.URL.Query().Get("includeRemoved") != "" {
+			includeRemoved, err = g.base.GetBoolQueryParam(r, "includeRemoved")
 			if err != nil {
 				g.base.SendJsonError(w, err.Error(), model.ErrQueryParam)
 				return
 			}
 		}
 
-		resp, err := g.service.List(r.Context(), offset, limit)
+		resp, err := g.service.Get(r.Context(), id, projectId, includeRemoved)
 		if err != nil {
 			g.base.SendJsonError(w, err.Error(), err)
 			return
 		}
 
+		g.base.SetETag(w, goodETag(resp.Version))
 		g.base.SendJsonResp(w, 200, resp)
 	}
 }
 
+func (g *Goods) List() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := g.listFilter(r)
+		if err != nil {
+			g.base.SendJsonError(w, err.Error(), model.ErrQueryParam)
+			return
+		}
+
+		resp, err := g.service.List(r.Context(), filter)
+		if err != nil {
+			g.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
+		g.base.SetETag(w, listETag(resp))
+		g.base.SendJsonResp(w, 200, resp)
+	}
+}
+
+// expectedVersion combines the versions from the If-Match header and the request
+func (g *Goods) expectedVersion(r *http.Request, version *int) (*int, error) {
+	headerVersion, err := g.base.GetIfMatchVersion(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case headerVersion == nil:
+		return version, nil
+	case version != nil && *version != *headerVersion:
+		return nil, fmt.Errorf("%w: the If-Match header and the version differ", model.ErrValidate)
+	default:
+		return headerVersion, nil
+	}
+}
+
+// goodETag is the ETag of a single good, the good's version
+func goodETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// listETag is a weak ETag of a goods list page
+func listETag(list *model.ProductListResponce) string {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%d:%d:", list.Meta.Total, list.Meta.Removed)
+	for _, el := range list.Goods {
+		fmt.Fprintf(hash, "%d:%d,", el.ID, el.Version)
+	}
+
+	return fmt.Sprintf(`W/"%x"`, hash.Sum64())
+}
+
+// listFilter reads the page and the filters of the goods list from the query parameters
+func (g *Goods) listFilter(r *http.Request) (model.ProductListFilter, error) {
+	query := r.URL.Query()
+
+	filter := model.ProductListFilter{
+		Offset:  1,
+		Limit:   10,
+		Removed: model.RemovedFilterAll,
+		Name:    query.Get("name"),
+	}
+
+	var err error
+
+	if filter.Sort, err = model.ParseSort(query.Get("sort")); err != nil {
+		return filter, err
+	}
+
+	if query.Get("offset") != "" {
+		if filter.Offset, err = g.base.GetIntQueryParam(r, "offset"); err != nil {
+			return filter, err
+		}
+	}
+
+	if query.Get("limit") != "" {
+		if filter.Limit, err = g.base.GetIntQueryParam(r, "limit"); err != nil {
+			return filter, err
+		}
+	}
+
+	if filter.Offset < 1 {
+		return filter, fmt.Errorf("%w: offset must be positive", model.ErrQueryParam)
+	}
+
+	if filter.Limit < 1 || filter.Limit > listMaxLimit {
+		return filter, fmt.Errorf("%w: limit must be between 1 and %d", model.ErrQueryParam, listMaxLimit)
+	}
+
+	if query.Has("cursor") {
+		filter.CursorMode = true
+
+		if cursor := query.Get("cursor"); cursor != "" {
+			if filter.Cursor, err = model.DecodeCursor(cursor, filter.Sort); err != nil {
+				return filter, err
+			}
+		}
+	}
+
+	if query.Get("projectId") != "" {
+		if filter.ProjectID, err = g.base.GetIntQueryParam(r, "projectId"); err != nil {
+			return filter, err
+		}
+	}
+
+	switch removed := query.Get("removed"); removed {
+	case "":
+	case model.RemovedFilterAll, model.RemovedFilterTrue, model.RemovedFilterFalse:
+		filter.Removed = removed
+	default:
+		return filter, fmt.Errorf("removed must be one of %s, %s, %s",
+			model.RemovedFilterTrue, model.RemovedFilterFalse, model.RemovedFilterAll)
+	}
+
+	if query.Get("createdFrom") != "" {
+		createdFrom, _, err := g.base.GetTimeQueryParam(r, "createdFrom")
+		if err != nil {
+			return filter, err
+		}
+		filter.CreatedFrom = &createdFrom
+	}
+
+	if query.Get("createdTo") != "" {
+		createdTo, dateOnly, err := g.base.GetTimeQueryParam(r, "createdTo")
+		if err != nil {
+			return filter, err
+		}
+
+		// a date covers the whole day
+		if dateOnly {
+			createdBefore := createdTo.AddDate(0, 0, 1)
+			filter.CreatedBefore = &createdBefore
+		} else {
+			filter.CreatedTo = &createdTo
+		}
+	}
+
+	return filter, nil
+}
+
 func (g *Goods) Reprioritizy() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id, err := g.base.GetIntQueryParam(r, "id")
@@ -198,6 +642,16 @@ func (g *Goods) Reprioritizy() http.HandlerFunc {
 			return
 		}
 
+		if reqData.Version, err = g.expectedVersion(r, reqData.Version); err != nil {
+			g.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
+		if err := reqData.CheckTarget(); err != nil {
+			g.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
 		resp, err := g.service.Reprioritizy(r.Context(), reqData)
 		if err != nil {
 			g.base.SendJsonError(w, err.Error(), err)
@@ -207,3 +661,51 @@ func (g *Goods) Reprioritizy() http.HandlerFunc {
 		g.base.SendJsonResp(w, 200, resp)
 	}
 }
+
+// CheckPriorities reports projects with duplicated priorities or gaps, projectId is optional
+func (g *Goods) CheckPriorities() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var projectId int
+
+		if r.URL.Query().Get("projectId") != "" {
+			var err error
+			projectId, err = g.base.GetIntQueryParam(r, "projectId")
+			if err != nil {
+				g.base.SendJsonError(w, err.Error(), model.ErrQueryParam)
+				return
+			}
+		}
+
+		resp, err := g.service.CheckPriorities(r.Context(), projectId)
+		if err != nil {
+			g.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
+		g.base.SendJsonResp(w, 200, resp)
+	}
+}
+
+// RepairPriorities renumbers the goods of inconsistent projects, projectId is optional
+func (g *Goods) RepairPriorities() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var projectId int
+
+		if r.URL.Query().Get("projectId") != "" {
+			var err error
+			projectId, err = g.base.GetIntQueryParam(r, "projectId")
+			if err != nil {
+				g.base.SendJsonError(w, err.Error(), model.ErrQueryParam)
+				return
+			}
+		}
+
+		resp, err := g.service.RepairPriorities(r.Context(), projectId)
+		if err != nil {
+			g.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
+		g.base.SendJsonResp(w, 200, resp)
+	}
+}
@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hezzl/internal/model"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const (
+	idempotencyHeader       = "Idempotency-Key"
+	idempotencyReplayHeader = "Idempotent-Replayed"
+	idempotencyKeyMaxLength = 255
+)
+
+// replayedHeaders are the response headers stored together with the response body
+var replayedHeaders = []string{"Content-Type", "ETag"}
+
+type IIdempotencyRepo interface {
+	Reserve(ctx context.Context, key string, reservation model.IdempotencyRecord) (*model.IdempotencyRecord, error)
+	Save(key string, reservation, record model.IdempotencyRecord)
+	Release(key string, reservation model.IdempotencyRecord)
+}
+
+type Idempotency struct {
+	base *BaseController
+	repo IIdempotencyRepo
+}
+
+type IdempotencyDeps struct {
+	*BaseController
+	IIdempotencyRepo
+}
+
+func NewIdempotency(deps *IdempotencyDeps) *Idempotency {
+	return &Idempotency{
+		base: deps.BaseController,
+		repo: deps.IIdempotencyRepo,
+	}
+}
+
+// Middleware replays the stored response to a retry with the same Idempotency-Key
+func (i *Idempotency) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			idempotencyKey := r.Header.Get(idempotencyHeader)
+			if idempotencyKey == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(idempotencyKey) > idempotencyKeyMaxLength {
+				err := fmt.Errorf("%w: the %s header is longer than %d", model.ErrValidate, idempotencyHeader, idempotencyKeyMaxLength)
+				i.base.SendJsonError(w, err.Error(), err)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				i.base.SendJsonError(w, err.Error(), model.ErrValidate)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			// the key is scoped to the endpoint, the hash covers the rest of the request
+			key := fmt.Sprintf("%s %s:%s", r.Method, r.URL.Path, idempotencyKey)
+			requestHash := idempotencyHash(r, body)
+			reservation := model.IdempotencyRecord{Hash: requestHash, Token: uuid.NewString()}
+
+			record, err := i.repo.Reserve(r.Context(), key, reservation)
+			switch {
+			case err != nil:
+				i.base.Log.Error("idempotency check skipped", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			case record != nil && record.Hash != requestHash:
+				i.base.SendJsonError(w, model.ErrIdempotencyMismatch.Error(), model.ErrIdempotencyMismatch)
+				return
+			case record != nil && !record.Done():
+				i.base.SendJsonError(w, model.ErrIdempotencyInProgress.Error(), model.ErrIdempotencyInProgress)
+				return
+			case record != nil:
+				for name, value := range record.Header {
+					w.Header().Set(name, value)
+				}
+				w.Header().Set(idempotencyReplayHeader, "true")
+				w.WriteHeader(record.Status)
+				if _, err := w.Write(record.Body); err != nil {
+					i.base.Log.Error("failed to write the replayed response", "error", err)
+				}
+				return
+			}
+
+			// the key is released unless the response is saved, also when the handler panics
+			saved := false
+			defer func() {
+				if !saved {
+					i.repo.Release(key, reservation)
+				}
+			}()
+
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			if recorder.status >= http.StatusInternalServerError {
+				return
+			}
+
+			result := model.IdempotencyRecord{
+				Header: make(map[string]string, len(replayedHeaders)),
+				Hash:   requestHash,
+				Body:   recorder.body.Bytes(),
+				Status: recorder.status,
+			}
+			for _, name := range replayedHeaders {
+				if value := w.Header().Get(name); value != "" {
+					result.Header[name] = value
+				}
+			}
+
+			i.repo.Save(key, reservation, result)
+			saved = true
+		})
+	}
+}
+
+// idempotencyHash identifies the request by its query, precondition and body
+func idempotencyHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.URL.RawQuery))
+	hash.Write([]byte{0})
+	hash.Write([]byte(r.Header.Get("If-Match")))
+	hash.Write([]byte{0})
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder writes the response through and keeps a copy of its status and body
+type responseRecorder struct {
+	http.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"hezzl/internal/model"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeIdempotencyRepo returns the stored record on Reserve and records the calls
+type fakeIdempotencyRepo struct {
+	record      *model.IdempotencyRecord
+	err         error
+	reservation model.IdempotencyRecord
+	saved       *model.IdempotencyRecord
+	released    bool
+}
+
+func (r *fakeIdempotencyRepo) Reserve(ctx context.Context, key string, reservation model.IdempotencyRecord) (*model.IdempotencyRecord, error) {
+	r.reservation = reservation
+	if r.record != nil && r.record.Hash == "" {
+		r.record.Hash = reservation.Hash
+	}
+	return r.record, r.err
+}
+
+func (r *fakeIdempotencyRepo) Save(key string, reservation, record model.IdempotencyRecord) {
+	if reservation.Token == r.reservation.Token {
+		r.saved = &record
+	}
+}
+
+func (r *fakeIdempotencyRepo) Release(key string, reservation model.IdempotencyRecord) {
+	if reservation.Token == r.reservation.Token {
+		r.released = true
+	}
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	tests := []struct {
+		name         string
+		key          string
+		record       *model.IdempotencyRecord
+		reserveErr   error
+		handler      http.HandlerFunc
+		wantStatus   int
+		wantBody     string
+		wantRuns     int
+		wantSaved    bool
+		wantReleased bool
+		wantPanic    bool
+	}{
+		{
+			name:       "no key",
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusCreated) },
+			wantStatus: http.StatusCreated,
+			wantRuns:   1,
+		},
+		{
+			name: "new key",
+			key:  "k1",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				io.WriteString(w, "created")
+			},
+			wantStatus: http.StatusCreated,
+			wantBody:   "created",
+			wantRuns:   1,
+			wantSaved:  true,
+		},
+		{
+			name:         "server error",
+			key:          "k1",
+			handler:      func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) },
+			wantStatus:   http.StatusInternalServerError,
+			wantRuns:     1,
+			wantReleased: true,
+		},
+		{
+			name:         "panic",
+			key:          "k1",
+			handler:      func(w http.ResponseWriter, r *http.Request) { panic("handler failed") },
+			wantRuns:     1,
+			wantReleased: true,
+			wantPanic:    true,
+		},
+		{
+			name:       "replay",
+			key:        "k1",
+			record:     &model.IdempotencyRecord{Status: http.StatusCreated, Body: []byte("created")},
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) },
+			wantStatus: http.StatusCreated,
+			wantBody:   "created",
+		},
+		{
+			name:       "in progress",
+			key:        "k1",
+			record:     &model.IdempotencyRecord{},
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) },
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "other request",
+			key:        "k1",
+			record:     &model.IdempotencyRecord{Hash: "other", Status: http.StatusCreated},
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) },
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "storage unavailable",
+			key:        "k1",
+			reserveErr: errors.New("connection refused"),
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusCreated) },
+			wantStatus: http.StatusCreated,
+			wantRuns:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeIdempotencyRepo{record: tt.record, err: tt.reserveErr}
+			idempotency := NewIdempotency(&IdempotencyDeps{
+				BaseController:   NewBaseController(&BaseControllerDeps{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}),
+				IIdempotencyRepo: repo,
+			})
+
+			var runs int
+			handler := idempotency.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				runs++
+				tt.handler(w, r)
+			}))
+
+			r := httptest.NewRequest(http.MethodPost, "/good/create", strings.NewReader(`{"name":"apple"}`))
+			if tt.key != "" {
+				r.Header.Set(idempotencyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+
+			func() {
+				defer func() {
+					if recovered := recover(); (recovered != nil) != tt.wantPanic {
+						t.Errorf("panic = %v, wantPanic %v", recovered, tt.wantPanic)
+					}
+				}()
+				handler.ServeHTTP(w, r)
+			}()
+
+			if tt.key != "" && repo.reservation.Token == "" {
+				t.Errorf("key reserved without a token")
+			}
+
+			if runs != tt.wantRuns {
+				t.Errorf("handler runs = %d, want %d", runs, tt.wantRuns)
+			}
+
+			if (repo.saved != nil) != tt.wantSaved {
+				t.Errorf("saved = %v, want %v", repo.saved != nil, tt.wantSaved)
+			}
+
+			if repo.released != tt.wantReleased {
+				t.Errorf("released = %v, want %v", repo.released, tt.wantReleased)
+			}
+
+			if tt.wantPanic {
+				return
+			}
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestIdempotencyHash(t *testing.T) {
+	request := func(target, ifMatch string) *http.Request {
+		r := httptest.NewRequest(http.MethodPatch, target, nil)
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name     string
+		first    *http.Request
+		second   *http.Request
+		wantSame bool
+	}{
+		{
+			name:     "same request",
+			first:    request("/good/update?id=1", `"1"`),
+			second:   request("/good/update?id=1", `"1"`),
+			wantSame: true,
+		},
+		{
+			name:   "other precondition",
+			first:  request("/good/update?id=1", `"1"`),
+			second: request("/good/update?id=1", `"2"`),
+		},
+		{
+			name:   "no precondition",
+			first:  request("/good/update?id=1", `"1"`),
+			second: request("/good/update?id=1", ""),
+		},
+		{
+			name:   "other query",
+			first:  request("/good/update?id=1", ""),
+			second: request("/good/update?id=2", ""),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := []byte(`{"name":"apple"}`)
+			first, second := idempotencyHash(tt.first, body), idempotencyHash(tt.second, body)
+
+			if (first == second) != tt.wantSame {
+				t.Errorf("hashes are the same = %v, want %v", first == second, tt.wantSame)
+			}
+		})
+	}
+}
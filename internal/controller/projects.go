@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hezzl/internal/model"
+	"hezzl/pkg/validate"
+	"net/http"
+)
+
+type IProjectsService interface {
+	Create(ctx context.Context, data model.ProjectCreateRequest) (*model.Project, error)
+	Update(ctx context.Context, data model.ProjectUpdateRequest) (*model.Project, error)
+	Remove(ctx context.Context, id int) (*model.ProjectRemoveResponce, error)
+	CompactPriorities(ctx context.Context, id int) (*model.ProductReprioritizyResponce, error)
+	Get(ctx context.Context, id int) (*model.Project, error)
+	List(ctx context.Context, offset, limit int) (*model.ProjectListResponce, error)
+}
+
+type Projects struct {
+	base    *BaseController
+	service IProjectsService
+}
+
+type ProjectsDeps struct {
+	*BaseController
+	IProjectsService
+}
+
+func NewProjects(deps *ProjectsDeps) *Projects {
+	return &Projects{
+		base:    deps.BaseController,
+		service: deps.IProjectsService,
+	}
+}
+
+func (p *Projects) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var reqData model.ProjectCreateRequest
+
+		if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+			p.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
+		if err := validate.IsValid(reqData); err != nil {
+			p.base.SendJsonError(w, err.Error(), model.ErrValidate)
+			return
+		}
+
+		resp, err := p.service.Create(r.Context(), reqData)
+		if err != nil {
+			p.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
+		p.base.SendJsonResp(w, 201, resp)
+	}
+}
+
+func (p *Projects) Update() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := p.base.GetIntQueryParam(r, "id")
+		if err != nil {
+			p.base.SendJsonError(w, err.Error(), model.ErrQueryParam)
+			return
+		}
+
+		reqData := model.ProjectUpdateRequest{
+			ID: id,
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
+			p.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
+		if err := validate.IsValid(reqData); err != nil {
+			p.base.SendJsonError(w, err.Error(), model.ErrValidate)
+			return
+		}
+
+		resp, err := p.service.Update(r.Context(), reqData)
+		if err != nil {
+			p.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
+		p.base.SendJsonResp(w, 200, resp)
+	}
+}
+
+func (p *Projects) Remove() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := p.base.GetIntQueryParam(r, "id")
+		if err != nil {
+			p.base.SendJsonError(w, err.Error(), model.ErrQueryParam)
+			return
+		}
+
+		resp, err := p.service.Remove(r.Context(), id)
+		if err != nil {
+			p.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
+		p.base.SendJsonResp(w, 200, resp)
+	}
+}
+
+func (p *Projects) CompactPriorities() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := p.base.GetIntQueryParam(r, "projectId")
+		if err != nil {
+			p.base.SendJsonError(w, err.Error(), model.ErrQueryParam)
+			return
+		}
+
+		resp, err := p.service.CompactPriorities(r.Context(), projectId)
+		if err != nil {
+			p.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
+		p.base.SendJsonResp(w, 200, resp)
+	}
+}
+
+func (p *Projects) Get() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := p.base.GetIntQueryParam(r, "id")
+		if err != nil {
+			p.base.SendJsonError(w, err.Error(), model.ErrQueryParam)
+			return
+		}
+
+		resp, err := p.service.Get(r.Context(), id)
+		if err != nil {
+			p.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
+		p.base.SendJsonResp(w, 200, resp)
+	}
+}
+
+func (p *Projects) List() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		offset, limit, err := p.listPage(r)
+		if err != nil {
+			p.base.SendJsonError(w, err.Error(), model.ErrQueryParam)
+			return
+		}
+
+		resp, err := p.service.List(r.Context(), offset, limit)
+		if err != nil {
+			p.base.SendJsonError(w, err.Error(), err)
+			return
+		}
+
+		p.base.SendJsonResp(w, 200, resp)
+	}
+}
+
+// listPage reads the offset and the limit of the projects list from the query parameters
+func (p *Projects) listPage(r *http.Request) (int, int, error) {
+	query := r.URL.Query()
+
+	offset, limit := 1, 10
+
+	var err error
+
+	if query.Get("offset") != "" {
+		if offset, err = p.base.GetIntQueryParam(r, "offset"); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if query.Get("limit") != "" {
+		if limit, err = p.base.GetIntQueryParam(r, "limit"); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if offset < 1 {
+		return 0, 0, fmt.Errorf("%w: offset must be positive", model.ErrQueryParam)
+	}
+
+	if limit < 1 || limit > listMaxLimit {
+		return 0, 0, fmt.Errorf("%w: limit must be between 1 and %d", model.ErrQueryParam, listMaxLimit)
+	}
+
+	return offset, limit, nil
+}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"hezzl/internal/model"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeProjectsService records the page of the projects list
+type fakeProjectsService struct {
+	IProjectsService
+	offset, limit int
+}
+
+func (s *fakeProjectsService) List(ctx context.Context, offset, limit int) (*model.ProjectListResponce, error) {
+	s.offset, s.limit = offset, limit
+	return &model.ProjectListResponce{}, nil
+}
+
+func TestProjectsList(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantOffset int
+		wantLimit  int
+	}{
+		{
+			name:       "default page",
+			wantStatus: http.StatusOK,
+			wantOffset: 1,
+			wantLimit:  10,
+		},
+		{
+			name:       "page",
+			query:      "?offset=3&limit=1000",
+			wantStatus: http.StatusOK,
+			wantOffset: 3,
+			wantLimit:  1000,
+		},
+		{
+			name:       "zero offset",
+			query:      "?offset=0",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "zero limit",
+			query:      "?limit=0",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "limit over max",
+			query:      "?limit=1001",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "not a number",
+			query:      "?offset=first",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeProjectsService{}
+			projects := NewProjects(&ProjectsDeps{
+				BaseController:   NewBaseController(&BaseControllerDeps{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}),
+				IProjectsService: service,
+			})
+
+			w := httptest.NewRecorder()
+			projects.List()(w, httptest.NewRequest(http.MethodGet, "/projects/list"+tt.query, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			if service.offset != tt.wantOffset || service.limit != tt.wantLimit {
+				t.Errorf("page = %d, %d, want %d, %d", service.offset, service.limit, tt.wantOffset, tt.wantLimit)
+			}
+		})
+	}
+}
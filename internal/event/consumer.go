@@ -0,0 +1,207 @@
+package event
+
+import (
+	"context"
+	"hezzl/internal/model"
+	"hezzl/pkg/broker"
+	"log/slog"
+	"time"
+)
+
+const (
+	retryDelayMin   = time.Second
+	retryDelayMax   = time.Minute
+	fetchBackoffMin = time.Millisecond * 100
+	fetchBackoffMax = time.Second * 30
+)
+
+type logConsumer struct {
+	log           *slog.Logger
+	subscriber    broker.Subscriber
+	logging       *logging
+	batchSize     int
+	flushInterval time.Duration
+	maxDeliver    int
+}
+
+type LogConsumerDeps struct {
+	*slog.Logger
+	broker.Subscriber
+	Logging       *logging
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+func NewLogConsumer(deps *LogConsumerDeps) *logConsumer {
+	return &logConsumer{
+		log:           deps.Logger,
+		subscriber:    deps.Subscriber,
+		logging:       deps.Logging,
+		batchSize:     deps.BatchSize,
+		flushInterval: deps.FlushInterval,
+		maxDeliver:    deps.Subscriber.MaxDeliver(),
+	}
+}
+
+// logBatch is the messages fetched since the last flush with the goods log rows decoded from them
+type logBatch struct {
+	started  time.Time
+	messages []batchMessage
+}
+
+type batchMessage struct {
+	msg  broker.Delivery
+	rows []model.GoodsLog
+}
+
+// Run writes the goods events to the goods log in batches until ctx is done
+func (c *logConsumer) Run(ctx context.Context) {
+	op := "event consumer: run"
+	log := c.log.With(slog.String("operation", op))
+	log.Info("consumer started", "batchSize", c.batchSize, "flushInterval", c.flushInterval)
+
+	// the pending batch is written after ctx is done, so the writes are not cancelled with it
+	flushCtx := context.WithoutCancel(ctx)
+
+	batch := logBatch{
+		messages: make([]batchMessage, 0, c.batchSize),
+	}
+	backoff := fetchBackoffMin
+
+	for ctx.Err() == nil {
+		maxWait := c.flushInterval
+		if len(batch.messages) > 0 {
+			maxWait = max(time.Until(batch.started.Add(c.flushInterval)), time.Millisecond)
+		}
+
+		msgs, err := c.subscriber.Fetch(ctx, c.batchSize-len(batch.messages), maxWait)
+
+		// the messages read before a failure are handled as well
+		for _, msg := range msgs {
+			c.add(flushCtx, &batch, msg)
+		}
+
+		if err != nil {
+			log.Error("failed to fetch messages", "error", err, "retryIn", backoff)
+
+			// the pending batch is written before the backoff
+			if len(batch.messages) > 0 {
+				c.flush(flushCtx, &batch)
+			}
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, fetchBackoffMax)
+			continue
+		}
+		backoff = fetchBackoffMin
+
+		if len(batch.messages) >= c.batchSize ||
+			(len(batch.messages) > 0 && time.Since(batch.started) >= c.flushInterval) {
+			c.flush(flushCtx, &batch)
+		}
+	}
+
+	if len(batch.messages) > 0 {
+		c.flush(flushCtx, &batch)
+	}
+
+	log.Info("consumer stopped")
+}
+
+// add puts the message into the batch
+func (c *logConsumer) add(ctx context.Context, batch *logBatch, msg broker.Delivery) {
+	rows, err := c.logging.LogRows(msg.Data())
+	if err != nil {
+		c.deadLetter(ctx, msg, err)
+		return
+	}
+
+	if len(batch.messages) == 0 {
+		batch.started = time.Now()
+	}
+
+	batch.messages = append(batch.messages, batchMessage{msg: msg, rows: rows})
+}
+
+// flush writes the batch to the goods log and acks or retries its messages
+func (c *logConsumer) flush(ctx context.Context, batch *logBatch) {
+	op := "event consumer: flush"
+	log := c.log.With(slog.String("operation", op))
+	log.Debug("Call func flush", "messages", len(batch.messages))
+
+	rows := make([]model.GoodsLog, 0, len(batch.messages))
+	for _, el := range batch.messages {
+		rows = append(rows, el.rows...)
+	}
+
+	err := c.logging.SendLogsToDB(ctx, rows)
+	switch {
+	case err == nil:
+		for _, el := range batch.messages {
+			c.ack(el.msg)
+		}
+	case len(batch.messages) == 1 || c.logging.PingDB(ctx) != nil:
+		log.Error("failed to write batch, messages will be redelivered", "messages", len(batch.messages), "error", err)
+		for _, el := range batch.messages {
+			c.retry(ctx, el.msg, err)
+		}
+	default:
+		log.Warn("failed to write batch, writing messages one by one", "messages", len(batch.messages), "error", err)
+		for _, el := range batch.messages {
+			if err := c.logging.SendLogsToDB(ctx, el.rows); err != nil {
+				c.retry(ctx, el.msg, err)
+				continue
+			}
+			c.ack(el.msg)
+		}
+	}
+
+	batch.messages = batch.messages[:0]
+}
+
+func (c *logConsumer) ack(msg broker.Delivery) {
+	if err := msg.Ack(); err != nil {
+		c.log.Error("failed to ack message", "error", err)
+	}
+}
+
+// retry naks the message with a delay growing with its deliveries
+func (c *logConsumer) retry(ctx context.Context, msg broker.Delivery, reason error) {
+	if c.maxDeliver > 0 && msg.Delivered() >= c.maxDeliver {
+		c.deadLetter(ctx, msg, reason)
+		return
+	}
+
+	if err := msg.Nak(retryDelay(msg.Delivered())); err != nil {
+		c.log.Error("failed to nak message", "error", err)
+	}
+}
+
+// deadLetter moves the message to the dead-letter stream
+func (c *logConsumer) deadLetter(ctx context.Context, msg broker.Delivery, reason error) {
+	if err := c.logging.sendToDeadLetters(ctx, msg, reason); err != nil {
+		c.log.Error("failed to send message to dead letters", "subject", msg.Subject(), "error", err)
+		if err := msg.Nak(retryDelayMax); err != nil {
+			c.log.Error("failed to nak message", "error", err)
+		}
+		return
+	}
+
+	c.log.Warn("message sent to dead letters", "subject", msg.Subject(), "delivered", msg.Delivered(), "reason", reason)
+	if err := msg.Term(); err != nil {
+		c.log.Error("failed to term message", "error", err)
+	}
+}
+
+// retryDelay doubles from retryDelayMin with every delivery up to retryDelayMax
+func retryDelay(delivered int) time.Duration {
+	delay := retryDelayMin
+	for i := 1; i < delivered && delay < retryDelayMax; i++ {
+		delay *= 2
+	}
+
+	return min(delay, retryDelayMax)
+}
@@ -0,0 +1,330 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hezzl/internal/model"
+	"hezzl/pkg/broker"
+	"hezzl/pkg/broker/memory"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// fakeLogsRepo keeps the written rows
+type fakeLogsRepo struct {
+	mu      sync.Mutex
+	rows    []model.GoodsLog
+	writes  int
+	fail    func(write int, rows []model.GoodsLog) bool
+	pingErr error
+	written chan struct{}
+}
+
+func newFakeLogsRepo() *fakeLogsRepo {
+	return &fakeLogsRepo{written: make(chan struct{}, 100)}
+}
+
+func (r *fakeLogsRepo) CreateBatch(ctx context.Context, rows []model.GoodsLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.writes++
+	if r.fail != nil && r.fail(r.writes, rows) {
+		return errors.New("write failed")
+	}
+
+	r.rows = append(r.rows, rows...)
+	r.written <- struct{}{}
+	return nil
+}
+
+func (r *fakeLogsRepo) Ping(ctx context.Context) error {
+	return r.pingErr
+}
+
+func (r *fakeLogsRepo) goodIds() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]int, 0, len(r.rows))
+	for _, el := range r.rows {
+		ids = append(ids, el.ID)
+	}
+	return ids
+}
+
+// failingSubscriber returns its deliveries with the first fetch and fails every fetch after it
+type failingSubscriber struct {
+	mu         sync.Mutex
+	deliveries []broker.Delivery
+}
+
+func (s *failingSubscriber) Fetch(ctx context.Context, batch int, maxWait time.Duration) ([]broker.Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.deliveries != nil {
+		deliveries := s.deliveries
+		s.deliveries = nil
+		return deliveries, nil
+	}
+	return nil, errors.New("broker is down")
+}
+
+func (s *failingSubscriber) MaxDeliver() int {
+	return 0
+}
+
+// fakeDelivery counts the acks, naks and terms of the message
+type fakeDelivery struct {
+	mu                sync.Mutex
+	data              []byte
+	acks, naks, terms int
+}
+
+func (d *fakeDelivery) Subject() string { return "goods.1.created" }
+func (d *fakeDelivery) Data() []byte    { return d.data }
+func (d *fakeDelivery) Delivered() int  { return 1 }
+
+func (d *fakeDelivery) Ack() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.acks++
+	return nil
+}
+
+func (d *fakeDelivery) Nak(delay time.Duration) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.naks++
+	return nil
+}
+
+func (d *fakeDelivery) Term() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.terms++
+	return nil
+}
+
+// createdEvent is the message of the created event of the good
+func createdEvent(t *testing.T, id int) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(model.NewGoodsEvent(context.Background(), model.EventCreated, nil, &model.Product{ID: id, ProjectID: 1, Name: "good"}))
+	if err != nil {
+		t.Fatalf("failed to marshal event: %s", err)
+	}
+	return data
+}
+
+// waitWritten waits until the repository has written count batches
+func waitWritten(t *testing.T, repo *fakeLogsRepo, count int, timeout time.Duration) {
+	t.Helper()
+
+	deadline := time.After(timeout)
+	for range count {
+		select {
+		case <-repo.written:
+		case <-deadline:
+			t.Fatalf("goods log was not written in %s", timeout)
+		}
+	}
+}
+
+func TestLogConsumerFlushesBeforeFetchBackoff(t *testing.T) {
+	repo := newFakeLogsRepo()
+	deliveries := []*fakeDelivery{{data: createdEvent(t, 1)}, {data: createdEvent(t, 2)}}
+	subscriber := &failingSubscriber{deliveries: []broker.Delivery{deliveries[0], deliveries[1]}}
+
+	consumer := NewLogConsumer(&LogConsumerDeps{
+		Logger:     testLogger,
+		Subscriber: subscriber,
+		Logging: NewLogging(&LoggingDeps{
+			Logger:    testLogger,
+			ILogsRepo: repo,
+		}),
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		consumer.Run(ctx)
+		close(done)
+	}()
+
+	// the batch is neither full nor due, only the failed fetch makes it written
+	waitWritten(t, repo, 1, time.Second)
+	cancel()
+	<-done
+
+	if got := repo.goodIds(); len(got) != 2 {
+		t.Errorf("written goods = %v, want 2 goods", got)
+	}
+
+	for i, el := range deliveries {
+		if el.acks != 1 {
+			t.Errorf("message %d acked %d times, want 1", i, el.acks)
+		}
+	}
+}
+
+// hasGood reports whether the rows are of the good
+func hasGood(rows []model.GoodsLog, id int) bool {
+	return slices.ContainsFunc(rows, func(row model.GoodsLog) bool { return row.ID == id })
+}
+
+func TestLogConsumer(t *testing.T) {
+	tests := []struct {
+		name          string
+		batchSize     int
+		flushInterval time.Duration
+		maxDeliver    int
+		fail          func(write int, rows []model.GoodsLog) bool
+		pingErr       error
+		// messages are the goods of the created events, 0 is a message that can not be decoded
+		messages    []int
+		wantWritten []int
+		wantDead    []int
+	}{
+		{
+			name:          "full batch",
+			batchSize:     2,
+			flushInterval: time.Hour,
+			messages:      []int{1, 2},
+			wantWritten:   []int{1, 2},
+		},
+		{
+			name:          "batch after flush interval",
+			batchSize:     10,
+			flushInterval: 50 * time.Millisecond,
+			messages:      []int{1},
+			wantWritten:   []int{1},
+		},
+		{
+			name:          "redelivered while goods log is unavailable",
+			batchSize:     2,
+			flushInterval: time.Hour,
+			fail:          func(write int, rows []model.GoodsLog) bool { return write == 1 },
+			pingErr:       errors.New("connection refused"),
+			messages:      []int{1, 2},
+			wantWritten:   []int{1, 2},
+		},
+		{
+			name:          "broken message written alone",
+			batchSize:     2,
+			flushInterval: time.Hour,
+			maxDeliver:    1,
+			fail:          func(write int, rows []model.GoodsLog) bool { return hasGood(rows, 2) },
+			messages:      []int{1, 2},
+			wantWritten:   []int{1},
+			wantDead:      []int{2},
+		},
+		{
+			name:          "undecodable message",
+			batchSize:     1,
+			flushInterval: time.Hour,
+			messages:      []int{0, 1},
+			wantWritten:   []int{1},
+			wantDead:      []int{0},
+		},
+		{
+			name:          "dead letter after max deliver",
+			batchSize:     1,
+			flushInterval: time.Hour,
+			maxDeliver:    2,
+			fail:          func(write int, rows []model.GoodsLog) bool { return true },
+			pingErr:       errors.New("connection refused"),
+			messages:      []int{1},
+			wantDead:      []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := memory.New(10, tt.maxDeliver, time.Minute)
+			defer b.Close()
+			deadLetters := b.Subscribe(deadLetterPrefix + ">")
+
+			repo := newFakeLogsRepo()
+			repo.fail = tt.fail
+			repo.pingErr = tt.pingErr
+
+			consumer := NewLogConsumer(&LogConsumerDeps{
+				Logger:     testLogger,
+				Subscriber: b.Subscribe("goods.>"),
+				Logging: NewLogging(&LoggingDeps{
+					Logger:    testLogger,
+					Publisher: b,
+					ILogsRepo: repo,
+				}),
+				BatchSize:     tt.batchSize,
+				FlushInterval: tt.flushInterval,
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				consumer.Run(ctx)
+				close(done)
+			}()
+
+			goods := make(map[string]int, len(tt.messages))
+			for i, el := range tt.messages {
+				data := []byte("{")
+				if el != 0 {
+					data = createdEvent(t, el)
+				}
+				goods[string(data)] = el
+
+				err := b.Publish(context.Background(), &broker.Message{
+					Subject: "goods.1.created",
+					ID:      fmt.Sprintf("e%d", i),
+					Data:    data,
+				})
+				if err != nil {
+					t.Fatalf("failed to publish message: %s", err)
+				}
+			}
+
+			// the redeliveries wait for the retry delay of at least a second
+			deadline := time.Now().Add(3 * time.Second)
+			var dead []int
+			for time.Now().Before(deadline) && (len(repo.goodIds()) < len(tt.wantWritten) || len(dead) < len(tt.wantDead)) {
+				deliveries, err := deadLetters.Fetch(context.Background(), 10, 10*time.Millisecond)
+				if err != nil {
+					t.Fatalf("failed to fetch dead letters: %s", err)
+				}
+				for _, el := range deliveries {
+					dead = append(dead, goods[string(el.Data())])
+				}
+			}
+
+			cancel()
+			<-done
+
+			written := repo.goodIds()
+			slices.Sort(written)
+			if !slices.Equal(written, tt.wantWritten) {
+				t.Errorf("written goods = %v, want %v", written, tt.wantWritten)
+			}
+
+			slices.Sort(dead)
+			if !slices.Equal(dead, tt.wantDead) {
+				t.Errorf("dead letters = %v, want %v", dead, tt.wantDead)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hezzl/internal/model"
+	"hezzl/pkg/broker"
+	"hezzl/pkg/broker/nats"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	deadLetterPrefix          = "dead."
+	deadLetterHeaderSubject   = "Dead-Letter-Subject"
+	deadLetterHeaderReason    = "Dead-Letter-Reason"
+	deadLetterHeaderDelivered = "Dead-Letter-Delivered"
+)
+
+// DeadLetterStream is the name of the dead-letter stream of the events stream
+func DeadLetterStream(name string) string {
+	return name + "_dead"
+}
+
+// DeadLetterSubjects is the subject of the dead-letter stream
+func DeadLetterSubjects(name string) string {
+	return deadLetterPrefix + name + ".>"
+}
+
+// sendToDeadLetters publishes the message to the dead-letter subject
+func (e *logging) sendToDeadLetters(ctx context.Context, msg broker.Delivery, reason error) error {
+	return e.publisher.Publish(ctx, &broker.Message{
+		Header: map[string]string{
+			deadLetterHeaderSubject:   msg.Subject(),
+			deadLetterHeaderReason:    reason.Error(),
+			deadLetterHeaderDelivered: strconv.Itoa(msg.Delivered()),
+		},
+		Subject: deadLetterPrefix + msg.Subject(),
+		Data:    msg.Data(),
+	})
+}
+
+// deadLetters reads and redrives the dead-letter stream
+type deadLetters struct {
+	log    *slog.Logger
+	Broker *nats.NatsBroker
+}
+
+type DeadLettersDeps struct {
+	*slog.Logger
+	*nats.NatsBroker
+}
+
+func NewDeadLetters(deps *DeadLettersDeps) *deadLetters {
+	return &deadLetters{
+		log:    deps.Logger,
+		Broker: deps.NatsBroker,
+	}
+}
+
+// List returns up to limit dead letters starting from the oldest one
+func (d *deadLetters) List(ctx context.Context, limit int) ([]model.DeadLetter, error) {
+	op := "event dead letters: listing"
+	log := d.log.With(slog.String("operation", op))
+	log.Debug("Call func List", "limit", limit)
+
+	if limit <= 0 {
+		log.Warn("limit is not positive")
+		return nil, fmt.Errorf("%w: limit must be positive", model.ErrValidate)
+	}
+
+	stream, err := d.stream(ctx)
+	if err != nil {
+		log.Error("failed to get dead-letter stream", "error", err)
+		return nil, err
+	}
+
+	info := stream.CachedInfo()
+	result := make([]model.DeadLetter, 0, min(uint64(limit), info.State.Msgs))
+
+	for seq := info.State.FirstSeq; seq != 0 && seq <= info.State.LastSeq && len(result) < limit; seq++ {
+		msg, err := stream.GetMsg(ctx, seq)
+		if errors.Is(err, jetstream.ErrMsgNotFound) {
+			// the sequence of a redriven message
+			continue
+		}
+		if err != nil {
+			log.Error("failed to get dead letter", "seq", seq, "error", err)
+			return nil, err
+		}
+
+		result = append(result, deadLetter(msg))
+	}
+
+	log.Info("successfully listed", "count", len(result))
+	return result, nil
+}
+
+// Redrive publishes the dead letter again and deletes it from the dead-letter stream
+func (d *deadLetters) Redrive(ctx context.Context, seq uint64) error {
+	op := "event dead letters: redriving"
+	log := d.log.With(slog.String("operation", op))
+	log.Debug("Call func Redrive", "seq", seq)
+
+	stream, err := d.stream(ctx)
+	if err != nil {
+		log.Error("failed to get dead-letter stream", "error", err)
+		return err
+	}
+
+	msg, err := stream.GetMsg(ctx, seq)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrMsgNotFound) {
+			log.Warn("dead letter not found", "error", err)
+			return model.ErrNotFound
+		}
+		log.Error("failed to get dead letter", "error", err)
+		return err
+	}
+
+	letter := deadLetter(msg)
+	if err := d.Broker.Publish(ctx, &broker.Message{Subject: letter.Subject, Data: letter.Data}); err != nil {
+		log.Error("failed to publish dead letter", "error", err)
+		return err
+	}
+
+	if err := stream.DeleteMsg(ctx, seq); err != nil {
+		log.Error("failed to delete dead letter", "error", err)
+		return err
+	}
+
+	log.Info("successfully redriven", "subject", letter.Subject)
+	return nil
+}
+
+func (d *deadLetters) stream(ctx context.Context) (jetstream.Stream, error) {
+	js, err := jetstream.New(d.Broker.Conn)
+	if err != nil {
+		return nil, err
+	}
+
+	return js.Stream(ctx, DeadLetterStream(d.Broker.NameMess))
+}
+
+func deadLetter(msg *jetstream.RawStreamMsg) model.DeadLetter {
+	letter := model.DeadLetter{
+		StoredAt: msg.Time,
+		Subject:  msg.Header.Get(deadLetterHeaderSubject),
+		Reason:   msg.Header.Get(deadLetterHeaderReason),
+		Data:     msg.Data,
+		Seq:      msg.Sequence,
+	}
+	letter.Delivered, _ = strconv.Atoi(msg.Header.Get(deadLetterHeaderDelivered))
+
+	if letter.Subject == "" {
+		letter.Subject = strings.TrimPrefix(msg.Subject, deadLetterPrefix)
+	}
+
+	return letter
+}
@@ -0,0 +1,34 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"hezzl/internal/model"
+	"testing"
+)
+
+func TestDeadLettersListLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+	}{
+		{
+			name:  "zero",
+			limit: 0,
+		},
+		{
+			name:  "negative",
+			limit: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deadLetters := NewDeadLetters(&DeadLettersDeps{Logger: testLogger})
+
+			if _, err := deadLetters.List(context.Background(), tt.limit); !errors.Is(err, model.ErrValidate) {
+				t.Errorf("List() error = %v, want %v", err, model.ErrValidate)
+			}
+		})
+	}
+}
@@ -1,73 +1,254 @@
 package event
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"hezzl/internal/model"
-	"hezzl/pkg/broker/nats"
+	"hezzl/pkg/broker"
 	"log/slog"
+	"time"
 )
 
 type ILogsRepo interface {
-	Create(data *model.Product)
+	CreateBatch(ctx context.Context, rows []model.GoodsLog) error
+	Ping(ctx context.Context) error
 }
 
 type logging struct {
-	log    *slog.Logger
-	Broker *nats.NatsBroker
-	repo   ILogsRepo
+	log       *slog.Logger
+	publisher broker.Publisher
+	subject   string
+	repo      ILogsRepo
 }
 
+// LoggingDeps of the goods events, Subject is the subject of the events stream without the wildcard
 type LoggingDeps struct {
 	*slog.Logger
-	*nats.NatsBroker
+	broker.Publisher
+	Subject string
 	ILogsRepo
 }
 
 func NewLogging(deps *LoggingDeps) *logging {
 	return &logging{
-		log:    deps.Logger,
-		Broker: deps.NatsBroker,
-		repo:   deps.ILogsRepo,
+		log:       deps.Logger,
+		publisher: deps.Publisher,
+		subject:   deps.Subject,
+		repo:      deps.ILogsRepo,
 	}
 }
 
-func (e *logging) SendToBroker(data *model.Product) {
-	op := "event logging: send to broker"
+// Publish sends the outbox message to the broker
+func (e *logging) Publish(msg *model.OutboxMessage) error {
+	op := "event logging: publish"
 	log := e.log.With(slog.String("operation", op))
-	log.Debug("Call func SendToBroker", "data", data)
+	log.Debug("Call func Publish", "eventId", msg.EventID, "type", msg.Type, "projectId", msg.ProjectID)
 
-	mess, err := json.Marshal(data)
+	err := e.publisher.Publish(context.Background(), &broker.Message{
+		Subject: Subject(e.subject, msg.ProjectID, msg.Type),
+		ID:      msg.EventID,
+		Data:    msg.Payload,
+	})
 	if err != nil {
-		log.Error("failed to marshal json", "error", err)
-		return
+		log.Error("failed to publish message to broker", "error", err)
+		return err
 	}
 
-	// if err := e.Broker.Conn.Publish(e.Broker.NameMess, mess); err != nil {
-	// 	log.Error("failed to publish message to nats", "error", err)
-	// 	return
-	// }
+	log.Info("successfully sent to broker")
+	return nil
+}
+
+// PublishBatch publishes the messages one by one, stops at the first failed one
+func (e *logging) PublishBatch(ctx context.Context, msgs []model.OutboxMessage) error {
+	for i := range msgs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := e.Publish(&msgs[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteLogs writes the messages to the goods log without the broker
+func (e *logging) WriteLogs(ctx context.Context, msgs []model.OutboxMessage) error {
+	rows := make([]model.GoodsLog, 0, len(msgs))
+	for _, el := range msgs {
+		msgRows, err := e.LogRows(el.Payload)
+		if err != nil {
+			return fmt.Errorf("event %s: %w", el.EventID, err)
+		}
+		rows = append(rows, msgRows...)
+	}
+
+	return e.SendLogsToDB(ctx, rows)
+}
+
+// Subject is the subject of the events of the type and project
+func Subject(prefix string, projectId int, eventType string) string {
+	return fmt.Sprintf("%s.%d.%s", prefix, projectId, eventType)
+}
 
-	_, err = e.Broker.Js.Publish(e.Broker.NameMess, mess)
+// LogRows decodes the message of an event into the rows of the goods log
+func (e *logging) LogRows(data []byte) ([]model.GoodsLog, error) {
+	op := "event logging: decoding log rows"
+	log := e.log.With(slog.String("operation", op))
+	log.Debug("Call func LogRows", "data", data)
+
+	event, err := decodeEvent(data)
 	if err != nil {
-		log.Error("failed to publish message to jetstream", "error", err)
-		return
+		log.Error("failed to unmarshal message", "error", err)
+		return nil, err
 	}
 
-	log.Info("successfully sent to broker")
+	rows, err := logRows(event)
+	if err != nil {
+		log.Error("failed to build log rows", "error", err)
+		return nil, err
+	}
+
+	return rows, nil
 }
 
-func (e *logging) SendLogToDB(data []byte) {
-	op := "event logging: send log to repository"
+// SendLogsToDB writes the rows of a batch of events to the goods log at once
+func (e *logging) SendLogsToDB(ctx context.Context, rows []model.GoodsLog) error {
+	op := "event logging: send logs to repository"
 	log := e.log.With(slog.String("operation", op))
-	log.Debug("Call func SendLogToDB", "data", data)
+	log.Debug("Call func SendLogsToDB", "count", len(rows))
 
-	var product model.Product
-	if err := json.Unmarshal(data, &product); err != nil {
-		e.log.Error("failed to unmarshal message", "error", err)
-		return
+	if err := e.repo.CreateBatch(ctx, rows); err != nil {
+		return err
 	}
 
-	e.repo.Create(&product)
+	log.Info("successfully sent to repo", "rows", len(rows))
+	return nil
+}
+
+// PingDB checks that the goods log is available
+func (e *logging) PingDB(ctx context.Context) error {
+	return e.repo.Ping(ctx)
+}
+
+// legacyEvent is the message published before the GoodsEvent envelope, a good with the event name
+type legacyEvent struct {
+	Changes    map[string]any          `json:"changes"`
+	Event      string                  `json:"event"`
+	Priorities []model.ProductPriority `json:"priorities"`
+	model.Product
+}
+
+// decodeEvent decodes the envelope, messages without the schema version are the legacy ones
+func decodeEvent(data []byte) (*model.GoodsEvent, error) {
+	// the legacy messages have a numeric id, so the version is read before the envelope
+	var version struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &version); err != nil {
+		return nil, err
+	}
+
+	if version.SchemaVersion != 0 {
+		var event model.GoodsEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, err
+		}
+		return &event, nil
+	}
+
+	var legacy legacyEvent
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, err
+	}
+
+	// the legacy messages have no time and no id
+	event := model.GoodsEvent{
+		OccurredAt: time.Now().UTC(),
+		ID:         model.DerivedEventID(data),
+		Changes:    legacy.Changes,
+		Type:       legacy.Event,
+		Priorities: legacy.Priorities,
+		ProjectID:  legacy.ProjectID,
+	}
+	if event.Type == "" {
+		event.Type = model.EventUpdated
+	}
+	if len(legacy.Priorities) == 0 {
+		event.After = &legacy.Product
+	}
+
+	return &event, nil
+}
+
+// logRows turns the event into the rows of the goods log
+func logRows(event *model.GoodsEvent) ([]model.GoodsLog, error) {
+	base := model.GoodsLog{
+		OccurredAt:    event.OccurredAt,
+		EventID:       event.ID,
+		Event:         event.Type,
+		Actor:         event.Actor,
+		SchemaVersion: event.SchemaVersion,
+	}
+
+	if len(event.Goods) > 0 {
+		rows := make([]model.GoodsLog, 0, len(event.Goods))
+		for _, el := range event.Goods {
+			row := base
+			row.Product = el
+			rows = append(rows, row)
+		}
+		return rows, nil
+	}
+
+	// the events of schema version 1 and the legacy ones have only the priorities of the goods
+	if len(event.Priorities) > 0 {
+		rows := make([]model.GoodsLog, 0, len(event.Priorities))
+		for _, el := range event.Priorities {
+			row := base
+			row.Product = model.Product{
+				ID:        el.ID,
+				ProjectID: el.ProjectID,
+				Priority:  el.Priority,
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	}
+
+	switch {
+	case event.After != nil:
+		base.Product = *event.After
+	case event.Before != nil:
+		base.Product = *event.Before
+	case event.GoodID != 0:
+		// the event carries only the changes, the rest of the good is in the earlier rows
+		base.Product = model.Product{
+			ID:        event.GoodID,
+			ProjectID: event.ProjectID,
+			Version:   event.Version,
+		}
+	default:
+		return nil, fmt.Errorf("event %s of type %q has no good", event.ID, event.Type)
+	}
+
+	if event.Before != nil && event.After != nil {
+		before, err := json.Marshal(event.Before)
+		if err != nil {
+			return nil, err
+		}
+		base.Before = string(before)
+	}
+
+	if len(event.Changes) > 0 {
+		changes, err := json.Marshal(event.Changes)
+		if err != nil {
+			return nil, err
+		}
+		base.Changes = string(changes)
+	}
 
-	log.Info("successfully sent to repo")
+	return []model.GoodsLog{base}, nil
 }
@@ -0,0 +1,225 @@
+package event
+
+import (
+	"hezzl/internal/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeEvent(t *testing.T) {
+	occurredAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		data    string
+		want    *model.GoodsEvent
+		wantErr bool
+	}{
+		{
+			name: "envelope",
+			data: `{"occurred_at":"2025-01-02T03:04:05Z","id":"e1","type":"created","actor":"admin","project_id":1,"schema_version":1,` +
+				`"after":{"id":2,"project_id":1,"name":"apple","priority":3,"version":1}}`,
+			want: &model.GoodsEvent{
+				OccurredAt:    occurredAt,
+				After:         &model.Product{ID: 2, ProjectID: 1, Name: "apple", Priority: 3, Version: 1},
+				ID:            "e1",
+				Type:          model.EventCreated,
+				Actor:         "admin",
+				ProjectID:     1,
+				SchemaVersion: 1,
+			},
+		},
+		{
+			name: "legacy good",
+			data: `{"event":"removed","id":2,"project_id":1,"name":"apple","priority":3,"removed":true}`,
+			want: &model.GoodsEvent{
+				After:     &model.Product{ID: 2, ProjectID: 1, Name: "apple", Priority: 3, Removed: true},
+				Type:      model.EventRemoved,
+				ProjectID: 1,
+			},
+		},
+		{
+			name: "legacy without event name",
+			data: `{"id":2,"project_id":1,"name":"apple","changes":{"name":"apple"}}`,
+			want: &model.GoodsEvent{
+				After:     &model.Product{ID: 2, ProjectID: 1, Name: "apple"},
+				Changes:   map[string]any{"name": "apple"},
+				Type:      model.EventUpdated,
+				ProjectID: 1,
+			},
+		},
+		{
+			name: "legacy priorities",
+			data: `{"event":"reprioritized","project_id":1,"priorities":[{"id":2,"project_id":1,"priority":1}]}`,
+			want: &model.GoodsEvent{
+				Type:       model.EventReprioritized,
+				Priorities: []model.ProductPriority{{ID: 2, ProjectID: 1, Priority: 1}},
+				ProjectID:  1,
+			},
+		},
+		{
+			name:    "not json",
+			data:    `{`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeEvent([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeEvent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			// the legacy messages are logged at the time of decoding with the id derived from the message
+			if tt.want.SchemaVersion == 0 {
+				if got.OccurredAt.IsZero() {
+					t.Errorf("decodeEvent() of a legacy message has no time")
+				}
+				got.OccurredAt = time.Time{}
+				tt.want.ID = model.DerivedEventID([]byte(tt.data))
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeEvent() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogRows(t *testing.T) {
+	occurredAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	before := &model.Product{ID: 2, ProjectID: 1, Name: "apple", Priority: 3, Version: 1}
+	after := &model.Product{ID: 2, ProjectID: 1, Name: "pear", Priority: 3, Version: 2}
+
+	base := model.GoodsLog{
+		OccurredAt:    occurredAt,
+		EventID:       "e1",
+		Actor:         "admin",
+		SchemaVersion: model.GoodsEventSchemaVersion,
+	}
+	event := func(eventType string) model.GoodsEvent {
+		return model.GoodsEvent{
+			OccurredAt:    occurredAt,
+			ID:            "e1",
+			Type:          eventType,
+			Actor:         "admin",
+			ProjectID:     1,
+			SchemaVersion: model.GoodsEventSchemaVersion,
+		}
+	}
+	row := func(eventType string, product model.Product, before, changes string) model.GoodsLog {
+		row := base
+		row.Event = eventType
+		row.Product = product
+		row.Before = before
+		row.Changes = changes
+		return row
+	}
+
+	tests := []struct {
+		name    string
+		event   func() model.GoodsEvent
+		want    []model.GoodsLog
+		wantErr bool
+	}{
+		{
+			name: "created",
+			event: func() model.GoodsEvent {
+				e := event(model.EventCreated)
+				e.After = before
+				return e
+			},
+			want: []model.GoodsLog{row(model.EventCreated, *before, "", "")},
+		},
+		{
+			name: "updated",
+			event: func() model.GoodsEvent {
+				e := event(model.EventUpdated)
+				e.GoodID = after.ID
+				e.Version = after.Version
+				e.Changes = after.Changes(before)
+				return e
+			},
+			want: []model.GoodsLog{row(
+				model.EventUpdated,
+				model.Product{ID: 2, ProjectID: 1, Version: 2},
+				"",
+				`{"name":"pear"}`,
+			)},
+		},
+		{
+			name: "updated with states",
+			event: func() model.GoodsEvent {
+				e := event(model.EventUpdated)
+				e.Before = before
+				e.After = after
+				e.Changes = after.Changes(before)
+				return e
+			},
+			want: []model.GoodsLog{row(
+				model.EventUpdated,
+				*after,
+				`{"id":2,"project_id":1,"name":"apple","description":"","priority":3,"removed":false,"created_at":"0001-01-01T00:00:00Z","version":1}`,
+				`{"name":"pear"}`,
+			)},
+		},
+		{
+			name: "purged",
+			event: func() model.GoodsEvent {
+				e := event(model.EventPurged)
+				e.Before = before
+				return e
+			},
+			want: []model.GoodsLog{row(model.EventPurged, *before, "", "")},
+		},
+		{
+			name: "reordered",
+			event: func() model.GoodsEvent {
+				e := event(model.EventReordered)
+				e.Goods = []model.Product{*before, *after}
+				return e
+			},
+			want: []model.GoodsLog{
+				row(model.EventReordered, *before, "", ""),
+				row(model.EventReordered, *after, "", ""),
+			},
+		},
+		{
+			name: "reprioritized with priorities",
+			event: func() model.GoodsEvent {
+				e := event(model.EventReprioritized)
+				e.Priorities = []model.ProductPriority{{ID: 2, ProjectID: 1, Priority: 1}, {ID: 3, ProjectID: 1, Priority: 2}}
+				return e
+			},
+			want: []model.GoodsLog{
+				row(model.EventReprioritized, model.Product{ID: 2, ProjectID: 1, Priority: 1}, "", ""),
+				row(model.EventReprioritized, model.Product{ID: 3, ProjectID: 1, Priority: 2}, "", ""),
+			},
+		},
+		{
+			name:    "no good",
+			event:   func() model.GoodsEvent { return event(model.EventUpdated) },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := tt.event()
+			got, err := logRows(&event)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("logRows() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("logRows() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// ProductCursor points at the good the goods list page is counted from
+type ProductCursor struct {
+	Sort     string   `json:"s"`
+	Values   []string `json:"v"`
+	ID       int      `json:"i"`
+	Backward bool     `json:"b,omitempty"`
+}
+
+// EncodeCursor returns the opaque representation of the cursor that is sent to clients
+func EncodeCursor(cursor ProductCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor created by EncodeCursor and checks that it belongs to the given sort
+func DecodeCursor(s string, sort []SortField) (*ProductCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid cursor: %w", ErrQueryParam, err)
+	}
+
+	var cursor ProductCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("%w: invalid cursor: %w", ErrQueryParam, err)
+	}
+
+	if cursor.ID == 0 || len(cursor.Values) != len(sort) {
+		return nil, fmt.Errorf("%w: invalid cursor", ErrQueryParam)
+	}
+
+	if cursor.Sort != SortSpec(sort) {
+		return nil, fmt.Errorf("%w: cursor was issued for sort %q", ErrQueryParam, cursor.Sort)
+	}
+
+	return &cursor, nil
+}
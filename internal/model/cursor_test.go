@@ -0,0 +1,81 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	sort := []SortField{{Field: "name"}, {Field: "created_at", Desc: true}}
+	cursor := ProductCursor{
+		Sort:   SortSpec(sort),
+		Values: []string{"apple", "2025-01-02T03:04:05Z"},
+		ID:     7,
+	}
+
+	tests := []struct {
+		name    string
+		s       string
+		sort    []SortField
+		want    *ProductCursor
+		wantErr error
+	}{
+		{
+			name: "round trip",
+			s:    EncodeCursor(cursor),
+			sort: sort,
+			want: &cursor,
+		},
+		{
+			name: "backward",
+			s:    EncodeCursor(ProductCursor{Sort: "-priority", Values: []string{"3"}, ID: 2, Backward: true}),
+			sort: DefaultSort,
+			want: &ProductCursor{Sort: "-priority", Values: []string{"3"}, ID: 2, Backward: true},
+		},
+		{
+			name:    "not base64",
+			s:       "not a cursor!",
+			sort:    sort,
+			wantErr: ErrQueryParam,
+		},
+		{
+			name:    "not json",
+			s:       base64.RawURLEncoding.EncodeToString([]byte("{")),
+			sort:    sort,
+			wantErr: ErrQueryParam,
+		},
+		{
+			name:    "missing id",
+			s:       EncodeCursor(ProductCursor{Sort: cursor.Sort, Values: cursor.Values}),
+			sort:    sort,
+			wantErr: ErrQueryParam,
+		},
+		{
+			name:    "values do not match sort",
+			s:       EncodeCursor(ProductCursor{Sort: cursor.Sort, Values: []string{"apple"}, ID: 7}),
+			sort:    sort,
+			wantErr: ErrQueryParam,
+		},
+		{
+			name:    "other sort",
+			s:       EncodeCursor(cursor),
+			sort:    []SortField{{Field: "name", Desc: true}, {Field: "created_at", Desc: true}},
+			wantErr: ErrQueryParam,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeCursor(tt.s, tt.sort)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DecodeCursor() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeCursor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// DeadLetter is a goods event message the events consumer gave up on
+type DeadLetter struct {
+	StoredAt  time.Time
+	Subject   string
+	Reason    string
+	Data      []byte
+	Seq       uint64
+	Delivered int
+}
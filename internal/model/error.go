@@ -24,4 +24,15 @@ var (
 	ErrNotFound        = errors.New("not found")
 	ErrMaxPriority     = errors.New("new priority cannot be higher than the current maximum priority")
 	ErrCurrentPriority = errors.New("new priority must be different from the old")
+	ErrConflict        = errors.New("the good was changed by someone else, its version differs from the expected one")
+	ErrNotRemoved      = errors.New("the good is not removed")
+	ErrBulkAborted     = errors.New("not applied because another item of the bulk operation failed")
+	ErrMediaType       = errors.New("unsupported content type")
+
+	ErrIdempotencyMismatch   = errors.New("the idempotency key was already used with another request")
+	ErrIdempotencyInProgress = errors.New("a request with the idempotency key is still in progress")
+
+	ErrAnchorNotFound     = errors.New("anchor good not found")
+	ErrAnchorOtherProject = errors.New("anchor good belongs to another project")
+	ErrAnchorRemoved      = errors.New("anchor good is removed")
 )
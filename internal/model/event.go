@@ -0,0 +1,127 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GoodsEventSchemaVersion is the version of the GoodsEvent envelope
+const GoodsEventSchemaVersion = 2
+
+const (
+	EventCreated       = "created"
+	EventUpdated       = "updated"
+	EventRemoved       = "removed"
+	EventRestored      = "restored"
+	EventPurged        = "purged"
+	EventReprioritized = "reprioritized"
+	EventReordered     = "reordered"
+	// EventSnapshot is the state of a good replayed to rebuild the goods log, not a change
+	EventSnapshot = "snapshot"
+)
+
+// eventNamespace is the namespace of the event ids derived from the content of the events
+var eventNamespace = uuid.MustParse("397e62b6-c8d5-4f6a-a9cf-4fcf343d3d11")
+
+// DerivedEventID is the id of an event built from the name
+func DerivedEventID(name []byte) string {
+	return uuid.NewSHA1(eventNamespace, name).String()
+}
+
+// GoodsEvent is the envelope published to the broker for every change of goods
+type GoodsEvent struct {
+	OccurredAt    time.Time         `json:"occurred_at"`
+	Before        *Product          `json:"before,omitempty"`
+	After         *Product          `json:"after,omitempty"`
+	Changes       map[string]any    `json:"changes,omitempty"`
+	ID            string            `json:"id"`
+	Type          string            `json:"type"`
+	Actor         string            `json:"actor,omitempty"`
+	Goods         []Product         `json:"goods,omitempty"`
+	Priorities    []ProductPriority `json:"priorities,omitempty"`
+	GoodID        int               `json:"good_id,omitempty"`
+	Version       int               `json:"version,omitempty"`
+	ProjectID     int               `json:"project_id"`
+	SchemaVersion int               `json:"schema_version"`
+}
+
+// NewGoodsEvent builds the event of one good, the actor is taken from ctx
+func NewGoodsEvent(ctx context.Context, eventType string, before, after *Product) *GoodsEvent {
+	event := newGoodsEvent(ctx, eventType)
+	event.Before = before
+	event.After = after
+
+	switch {
+	case after != nil:
+		event.ProjectID = after.ProjectID
+	case before != nil:
+		event.ProjectID = before.ProjectID
+	}
+
+	return event
+}
+
+// NewUpdatedEvent builds the updated event with the changed fields only
+func NewUpdatedEvent(ctx context.Context, before, after *Product) *GoodsEvent {
+	event := newGoodsEvent(ctx, EventUpdated)
+	event.GoodID = after.ID
+	event.ProjectID = after.ProjectID
+	event.Version = after.Version
+	event.Changes = after.Changes(before)
+
+	return event
+}
+
+// NewSnapshotEvent builds the snapshot event of the good
+func NewSnapshotEvent(ctx context.Context, product *Product) *GoodsEvent {
+	event := NewGoodsEvent(ctx, EventSnapshot, nil, product)
+	event.ID = DerivedEventID(fmt.Appendf(nil, "%s:%d:%d", EventSnapshot, product.ID, product.Version))
+
+	return event
+}
+
+// NewPrioritiesEvent builds the event of the goods whose priorities changed
+func NewPrioritiesEvent(ctx context.Context, eventType string, projectId int, goods []Product) *GoodsEvent {
+	event := newGoodsEvent(ctx, eventType)
+	event.ProjectID = projectId
+	event.Goods = goods
+
+	return event
+}
+
+func newGoodsEvent(ctx context.Context, eventType string) *GoodsEvent {
+	return &GoodsEvent{
+		OccurredAt:    time.Now().UTC(),
+		ID:            uuid.NewString(),
+		Type:          eventType,
+		Actor:         ActorFromContext(ctx),
+		SchemaVersion: GoodsEventSchemaVersion,
+	}
+}
+
+// GoodsLog is a row of the goods log
+type GoodsLog struct {
+	OccurredAt    time.Time
+	EventID       string
+	Event         string
+	Actor         string
+	Before        string
+	Changes       string
+	SchemaVersion int
+	Product
+}
+
+type actorKey struct{}
+
+// ContextWithActor returns a copy of ctx carrying the actor
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestNewUpdatedEvent(t *testing.T) {
+	before := &Product{ID: 2, ProjectID: 1, Name: "apple", Description: "red", Priority: 3, Version: 1}
+
+	tests := []struct {
+		name        string
+		after       Product
+		wantChanges map[string]any
+	}{
+		{
+			name:        "name",
+			after:       Product{ID: 2, ProjectID: 1, Name: "pear", Description: "red", Priority: 3, Version: 2},
+			wantChanges: map[string]any{"name": "pear"},
+		},
+		{
+			name:        "cleared description",
+			after:       Product{ID: 2, ProjectID: 1, Name: "apple", Priority: 3, Version: 2},
+			wantChanges: map[string]any{"description": ""},
+		},
+		{
+			name:        "nothing",
+			after:       Product{ID: 2, ProjectID: 1, Name: "apple", Description: "red", Priority: 3, Version: 2},
+			wantChanges: map[string]any{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := ContextWithActor(context.Background(), "admin")
+			event := NewUpdatedEvent(ctx, before, &tt.after)
+
+			if event.Before != nil || event.After != nil {
+				t.Errorf("NewUpdatedEvent() carries the states of the good")
+			}
+
+			if event.Type != EventUpdated || event.GoodID != 2 || event.ProjectID != 1 || event.Version != 2 || event.Actor != "admin" {
+				t.Errorf("NewUpdatedEvent() = %+v", event)
+			}
+
+			if !reflect.DeepEqual(event.Changes, tt.wantChanges) {
+				t.Errorf("NewUpdatedEvent() changes = %v, want %v", event.Changes, tt.wantChanges)
+			}
+		})
+	}
+}
+
+func TestNewSnapshotEvent(t *testing.T) {
+	product := &Product{ID: 2, ProjectID: 1, Name: "apple", Version: 3}
+	first := NewSnapshotEvent(context.Background(), product)
+
+	tests := []struct {
+		name     string
+		product  *Product
+		wantSame bool
+	}{
+		{
+			name:     "same state",
+			product:  &Product{ID: 2, ProjectID: 1, Name: "apple", Version: 3},
+			wantSame: true,
+		},
+		{
+			name:    "next version",
+			product: &Product{ID: 2, ProjectID: 1, Name: "pear", Version: 4},
+		},
+		{
+			name:    "other good",
+			product: &Product{ID: 5, ProjectID: 1, Name: "apple", Version: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := NewSnapshotEvent(context.Background(), tt.product)
+
+			if event.Type != EventSnapshot || event.After != tt.product {
+				t.Errorf("NewSnapshotEvent() = %+v", event)
+			}
+
+			if same := event.ID == first.ID; same != tt.wantSame {
+				t.Errorf("NewSnapshotEvent() id %s, the first one %s, want same %v", event.ID, first.ID, tt.wantSame)
+			}
+		})
+	}
+}
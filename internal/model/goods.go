@@ -1,6 +1,10 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
 type Product struct {
 	ID          int       `json:"id"`
@@ -10,6 +14,7 @@ type Product struct {
 	Priority    int       `json:"priority"`
 	Removed     bool      `json:"removed"`
 	CreatedAt   time.Time `json:"created_at"`
+	Version     int       `json:"version"`
 }
 
 type ProductCreateRequest struct {
@@ -17,39 +22,232 @@ type ProductCreateRequest struct {
 	ProjectID int    `json:"project_id" validate:"required"`
 }
 
+// ProductBulkResult is the outcome of one item of a bulk request
+type ProductBulkResult struct {
+	Product *Product `json:"product,omitempty"`
+	Error   string   `json:"error,omitempty"`
+	Index   int      `json:"index"`
+	Status  int      `json:"status"`
+}
+
+// Changes returns the fields that differ from the previous state
+func (p *Product) Changes(previous *Product) map[string]any {
+	changes := make(map[string]any, 4)
+
+	if p.Name != previous.Name {
+		changes["name"] = p.Name
+	}
+	if p.Description != previous.Description {
+		changes["description"] = p.Description
+	}
+	if p.Priority != previous.Priority {
+		changes["priority"] = p.Priority
+	}
+	if p.Removed != previous.Removed {
+		changes["removed"] = p.Removed
+	}
+
+	return changes
+}
+
+// ProductBulkOutcome is the outcome of one item of a bulk operation
+type ProductBulkOutcome struct {
+	Product *Product
+	Err     error
+}
+
+type ProductBulkResponce struct {
+	Results   []ProductBulkResult `json:"results"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+}
+
+// PatchString is a string field of a JSON Merge Patch
+type PatchString struct {
+	Value string
+	Set   bool
+	Null  bool
+}
+
+func (p *PatchString) UnmarshalJSON(data []byte) error {
+	p.Set = true
+
+	if string(data) == "null" {
+		p.Null = true
+		p.Value = ""
+		return nil
+	}
+
+	return json.Unmarshal(data, &p.Value)
+}
+
+func (p PatchString) MarshalJSON() ([]byte, error) {
+	if !p.Set || p.Null {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(p.Value)
+}
+
+// ProductUpdateRequest is a merge patch of the good
 type ProductUpdateRequest struct {
-	Name        string `json:"name" validate:"required"`
-	Description string `json:"description"`
-	ProjectID   int    `json:"project_id" validate:"required"`
-	ID          int    `json:"id" validate:"required"`
+	Version     *int        `json:"version" validate:"omitempty,min=1"`
+	Name        PatchString `json:"name"`
+	Description PatchString `json:"description"`
+	ProjectID   int         `json:"project_id" validate:"required"`
+	ID          int         `json:"id" validate:"required"`
+}
+
+// Check reports a patch that changes nothing or clears the name, returns a wrapped ErrValidate
+func (r *ProductUpdateRequest) Check() error {
+	switch {
+	case !r.Name.Set && !r.Description.Set:
+		return fmt.Errorf("%w: nothing to update, set name or description", ErrValidate)
+	case r.Name.Set && r.Name.Value == "":
+		return fmt.Errorf("%w: name can not be empty or null", ErrValidate)
+	}
+
+	return nil
+}
+
+// ProductRemoveRequest removes the good
+type ProductRemoveRequest struct {
+	Version   *int `json:"version" validate:"omitempty,min=1"`
+	ProjectID int  `json:"project_id" validate:"required"`
+	ID        int  `json:"id" validate:"required"`
+}
+
+// ProductRestoreRequest restores the removed good
+type ProductRestoreRequest struct {
+	Version   *int `json:"version" validate:"omitempty,min=1"`
+	ProjectID int  `json:"project_id" validate:"required"`
+	ID        int  `json:"id" validate:"required"`
 }
 
 type ProductRemoveResponce struct {
 	ID        int  `json:"id"`
 	ProjectID int  `json:"project_id"`
 	Removed   bool `json:"removed"`
+	Version   int  `json:"version"`
+}
+
+const (
+	RemovedFilterAll   = "all"
+	RemovedFilterTrue  = "true"
+	RemovedFilterFalse = "false"
+)
+
+// ProductListFilter describes the goods list page
+type ProductListFilter struct {
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// CreatedBefore is the exclusive bound of the creation time, set for a date-only createdTo
+	CreatedBefore *time.Time
+	Cursor        *ProductCursor
+	Name          string
+	Removed       string
+	Sort          []SortField
+	ProjectID     int
+	Offset        int
+	Limit         int
+	CursorMode    bool
+}
+
+type ProductPurgeResponce struct {
+	Goods  []ProductRemoveResponce `json:"goods"`
+	Purged int                     `json:"purged"`
 }
 
 type ProductListResponce struct {
 	Meta struct {
-		Total   int `json:"total"`
-		Removed int `json:"removed"`
-		Limit   int `json:"limit"`
-		Offset  int `json:"offset"`
+		NextCursor string `json:"next_cursor,omitempty"`
+		PrevCursor string `json:"prev_cursor,omitempty"`
+		Total      int    `json:"total"`
+		Removed    int    `json:"removed"`
+		Limit      int    `json:"limit"`
+		Offset     int    `json:"offset"`
 	} `json:"meta"`
 	Goods []Product `json:"goods"`
 }
 
+const (
+	ReprioritizyModeSwap = "swap"
+	ReprioritizyModeMove = "move"
+)
+
+const (
+	PositionTop    = "top"
+	PositionBottom = "bottom"
+)
+
+// ProductReprioritizyRequest changes the priority of a good
 type ProductReprioritizyRequest struct {
-	NewPriority int `json:"newPriority" validate:"required"`
-	ProjectID   int `json:"project_id" validate:"required"`
-	ID          int `json:"id" validate:"required"`
+	Version     *int   `json:"version" validate:"omitempty,min=1"`
+	Before      *int   `json:"before" validate:"omitempty,min=1"`
+	After       *int   `json:"after" validate:"omitempty,min=1"`
+	Mode        string `json:"mode" validate:"omitempty,oneof=swap move"`
+	Position    string `json:"position" validate:"omitempty,oneof=top bottom"`
+	NewPriority int    `json:"newPriority" validate:"omitempty,min=1"`
+	ProjectID   int    `json:"project_id" validate:"required"`
+	ID          int    `json:"id" validate:"required"`
+}
+
+// IsRelative tells whether the new place is given relative to another good or to the project
+func (r ProductReprioritizyRequest) IsRelative() bool {
+	return r.Before != nil || r.After != nil || r.Position != ""
+}
+
+// CheckTarget makes sure exactly one way of giving the new place is used
+func (r ProductReprioritizyRequest) CheckTarget() error {
+	var targets int
+	for _, set := range []bool{r.NewPriority != 0, r.Before != nil, r.After != nil, r.Position != ""} {
+		if set {
+			targets++
+		}
+	}
+
+	if targets != 1 {
+		return fmt.Errorf("%w: exactly one of newPriority, before, after, position must be set", ErrValidate)
+	}
+
+	for _, anchor := range []*int{r.Before, r.After} {
+		if anchor != nil && *anchor == r.ID {
+			return fmt.Errorf("%w: the good cannot be its own anchor", ErrValidate)
+		}
+	}
+
+	return nil
+}
+
+type ProductPriority struct {
+	ID        int `json:"id"`
+	ProjectID int `json:"project_id"`
+	Priority  int `json:"priority"`
+}
+
+// ProductPriorities returns the priorities of the goods
+func ProductPriorities(goods []Product) []ProductPriority {
+	priorities := make([]ProductPriority, 0, len(goods))
+	for _, el := range goods {
+		priorities = append(priorities, ProductPriority{ID: el.ID, ProjectID: el.ProjectID, Priority: el.Priority})
+	}
+
+	return priorities
 }
 
 type ProductReprioritizyResponce struct {
-	Priorities []struct {
-		ID        int `json:"id"`
-		ProjectID int `json:"project_id"`
-		Priority  int `json:"priority"`
-	} `json:"priorities"`
+	Priorities []ProductPriority `json:"priorities"`
+}
+
+// PriorityReport describes the priority problems of one project
+type PriorityReport struct {
+	ProjectID   int `json:"project_id"`
+	Goods       int `json:"goods"`
+	MaxPriority int `json:"max_priority"`
+	Duplicates  int `json:"duplicates"`
+	Gaps        int `json:"gaps"`
+}
+
+type PriorityCheckResponce struct {
+	Projects []PriorityReport `json:"projects"`
 }
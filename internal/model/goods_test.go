@@ -0,0 +1,164 @@
+package model
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestPatchStringUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    ProductUpdateRequest
+		wantErr bool
+	}{
+		{
+			name: "absent",
+			data: `{}`,
+			want: ProductUpdateRequest{},
+		},
+		{
+			name: "value",
+			data: `{"name": "apple"}`,
+			want: ProductUpdateRequest{Name: PatchString{Value: "apple", Set: true}},
+		},
+		{
+			name: "empty value",
+			data: `{"description": ""}`,
+			want: ProductUpdateRequest{Description: PatchString{Set: true}},
+		},
+		{
+			name: "null",
+			data: `{"description": null}`,
+			want: ProductUpdateRequest{Description: PatchString{Set: true, Null: true}},
+		},
+		{
+			name:    "not a string",
+			data:    `{"name": 1}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ProductUpdateRequest
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got.Name != tt.want.Name || got.Description != tt.want.Description {
+				t.Errorf("Unmarshal() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatchStringMarshalJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch PatchString
+		want  string
+	}{
+		{
+			name:  "absent",
+			patch: PatchString{},
+			want:  `null`,
+		},
+		{
+			name:  "null",
+			patch: PatchString{Set: true, Null: true},
+			want:  `null`,
+		},
+		{
+			name:  "value",
+			patch: PatchString{Value: "apple", Set: true},
+			want:  `"apple"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.patch)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProductUpdateRequestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		request ProductUpdateRequest
+		wantErr bool
+	}{
+		{
+			name:    "nothing to update",
+			request: ProductUpdateRequest{},
+			wantErr: true,
+		},
+		{
+			name:    "null name",
+			request: ProductUpdateRequest{Name: PatchString{Set: true, Null: true}},
+			wantErr: true,
+		},
+		{
+			name:    "empty name",
+			request: ProductUpdateRequest{Name: PatchString{Set: true}},
+			wantErr: true,
+		},
+		{
+			name:    "null description",
+			request: ProductUpdateRequest{Description: PatchString{Set: true, Null: true}},
+		},
+		{
+			name:    "name",
+			request: ProductUpdateRequest{Name: PatchString{Value: "apple", Set: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.request.Check(); (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProductPriorities(t *testing.T) {
+	tests := []struct {
+		name  string
+		goods []Product
+		want  []ProductPriority
+	}{
+		{
+			name:  "no goods",
+			goods: nil,
+			want:  []ProductPriority{},
+		},
+		{
+			name:  "goods",
+			goods: []Product{{ID: 2, ProjectID: 1, Name: "apple", Priority: 3}, {ID: 5, ProjectID: 1, Priority: 1}},
+			want:  []ProductPriority{{ID: 2, ProjectID: 1, Priority: 3}, {ID: 5, ProjectID: 1, Priority: 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProductPriorities(tt.goods); !slices.Equal(got, tt.want) {
+				t.Errorf("ProductPriorities() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package model
+
+// IdempotencyRecord is a request stored under its idempotency key
+type IdempotencyRecord struct {
+	Header map[string]string `json:"header,omitempty"`
+	Hash   string            `json:"hash"`
+	Token  string            `json:"token,omitempty"`
+	Body   []byte            `json:"body,omitempty"`
+	Status int               `json:"status"`
+}
+
+func (r *IdempotencyRecord) Done() bool {
+	return r.Status != 0
+}
@@ -0,0 +1,11 @@
+package model
+
+// OutboxMessage is a goods event stored in the outbox
+type OutboxMessage struct {
+	EventID   string
+	Type      string
+	Payload   []byte
+	ID        int64
+	ProjectID int
+	Attempts  int
+}
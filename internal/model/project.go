@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+type Project struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ProjectCreateRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type ProjectUpdateRequest struct {
+	Name string `json:"name" validate:"required"`
+	ID   int    `json:"id" validate:"required"`
+}
+
+type ProjectRemoveResponce struct {
+	ID           int  `json:"id"`
+	Removed      bool `json:"removed"`
+	RemovedGoods int  `json:"removed_goods"`
+}
+
+type ProjectListResponce struct {
+	Meta struct {
+		Total  int `json:"total"`
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
+	} `json:"meta"`
+	Projects []Project `json:"projects"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// Sources of the replayed events
+const (
+	// ReplaySourceState replays the current state of the goods as snapshot events
+	ReplaySourceState = "state"
+	// ReplaySourceOutbox replays the events kept in the outbox
+	ReplaySourceOutbox = "outbox"
+)
+
+// ReplayFilter selects the replayed events
+type ReplayFilter struct {
+	From      *time.Time
+	To        *time.Time
+	ProjectID int
+}
+
+type ReplayProgress struct {
+	Total int
+	Done  int
+}
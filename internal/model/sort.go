@@ -0,0 +1,62 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SortableFields lists the fields of Product the goods list can be sorted by
+var SortableFields = []string{"id", "project_id", "name", "description", "priority", "removed", "created_at"}
+
+// DefaultSort is the goods list order used when no sort is requested
+var DefaultSort = []SortField{{Field: "priority", Desc: true}}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort parses a sort spec like "priority,-created_at,name"
+func ParseSort(spec string) ([]SortField, error) {
+	if spec == "" {
+		return DefaultSort, nil
+	}
+
+	parts := strings.Split(spec, ",")
+	fields := make([]SortField, 0, len(parts))
+
+	for _, part := range parts {
+		field := SortField{Field: strings.TrimSpace(part)}
+		if strings.HasPrefix(field.Field, "-") {
+			field.Desc = true
+			field.Field = field.Field[1:]
+		}
+
+		if !slices.Contains(SortableFields, field.Field) {
+			return nil, fmt.Errorf("%w: unknown sort field %q", ErrQueryParam, field.Field)
+		}
+
+		if slices.ContainsFunc(fields, func(f SortField) bool { return f.Field == field.Field }) {
+			return nil, fmt.Errorf("%w: duplicate sort field %q", ErrQueryParam, field.Field)
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// SortSpec returns the canonical string form of the sort fields
+func SortSpec(fields []SortField) string {
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field.Desc {
+			parts = append(parts, "-"+field.Field)
+		} else {
+			parts = append(parts, field.Field)
+		}
+	}
+
+	return strings.Join(parts, ",")
+}
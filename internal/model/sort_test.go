@@ -0,0 +1,92 @@
+package model
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []SortField
+		wantErr error
+	}{
+		{
+			name: "empty is default",
+			spec: "",
+			want: DefaultSort,
+		},
+		{
+			name: "single ascending",
+			spec: "name",
+			want: []SortField{{Field: "name"}},
+		},
+		{
+			name: "mixed directions",
+			spec: "priority,-created_at,name",
+			want: []SortField{{Field: "priority"}, {Field: "created_at", Desc: true}, {Field: "name"}},
+		},
+		{
+			name: "spaces around fields",
+			spec: " -priority , id",
+			want: []SortField{{Field: "priority", Desc: true}, {Field: "id"}},
+		},
+		{
+			name:    "unknown field",
+			spec:    "price",
+			wantErr: ErrQueryParam,
+		},
+		{
+			name:    "duplicate field",
+			spec:    "name,-name",
+			wantErr: ErrQueryParam,
+		},
+		{
+			name:    "empty field",
+			spec:    "name,",
+			wantErr: ErrQueryParam,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSort(tt.spec)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseSort(%q) error = %v, want %v", tt.spec, err, tt.wantErr)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseSort(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortSpec(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []SortField
+		want   string
+	}{
+		{
+			name:   "default",
+			fields: DefaultSort,
+			want:   "-priority",
+		},
+		{
+			name:   "mixed directions",
+			fields: []SortField{{Field: "priority"}, {Field: "created_at", Desc: true}},
+			want:   "priority,-created_at",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SortSpec(tt.fields); got != tt.want {
+				t.Errorf("SortSpec() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
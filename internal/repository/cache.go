@@ -7,13 +7,15 @@ import (
 	"hezzl/internal/model"
 	"hezzl/pkg/db/redis"
 	"log/slog"
+	"net/url"
 	"strings"
 	"time"
 )
 
 const (
-	cacheName   = "goodsList:"
-	methodTimer = time.Second * 5
+	cacheName     = "goodsList:"
+	cacheItemName = "goodsItem:"
+	methodTimer   = time.Second * 5
 )
 
 type cacheRepo struct {
@@ -33,15 +35,15 @@ func NewCacheRepo(deps *CacheRepoDeps) *cacheRepo {
 	}
 }
 
-func (r *cacheRepo) AddGoodsList(data *model.ProductListResponce) {
+func (r *cacheRepo) AddGoodsList(filter model.ProductListFilter, data *model.ProductListResponce) {
 	op := "cache repository: creating"
 	log := r.log.With(slog.String("operation", op))
-	log.Debug("Call func AddGoodsList", "data", data)
+	log.Debug("Call func AddGoodsList", "filter", filter, "data", data)
 
 	ctx, cancel := context.WithTimeout(context.Background(), methodTimer)
 	defer cancel()
 
-	key := fmt.Sprintf("%soffset=%d:limit=%d", cacheName, data.Meta.Offset, data.Meta.Limit)
+	key := goodsListKey(filter)
 
 	jsonData, err := json.Marshal(data)
 	if err != nil {
@@ -57,15 +59,15 @@ func (r *cacheRepo) AddGoodsList(data *model.ProductListResponce) {
 	log.Info("successfully added")
 }
 
-func (r *cacheRepo) GetGoodsList(offset, limit int) *model.ProductListResponce {
+func (r *cacheRepo) GetGoodsList(filter model.ProductListFilter) *model.ProductListResponce {
 	op := "cache repository: retrieving"
 	log := r.log.With(slog.String("operation", op))
-	log.Debug("Call func GetGoodsList", "offset", offset, "limit", limit)
+	log.Debug("Call func GetGoodsList", "filter", filter)
 
 	ctx, cancel := context.WithTimeout(context.Background(), methodTimer)
 	defer cancel()
 
-	key := fmt.Sprintf("%soffset=%d:limit=%d", cacheName, offset, limit)
+	key := goodsListKey(filter)
 
 	result, err := r.Client.Get(ctx, key).Result()
 	switch {
@@ -88,6 +90,97 @@ func (r *cacheRepo) GetGoodsList(offset, limit int) *model.ProductListResponce {
 	}
 }
 
+// goodsListKey builds the key of a cached goods list page
+func goodsListKey(filter model.ProductListFilter) string {
+	var createdFrom, createdTo, createdBefore string
+	if filter.CreatedFrom != nil {
+		createdFrom = filter.CreatedFrom.UTC().Format(time.RFC3339Nano)
+	}
+	if filter.CreatedTo != nil {
+		createdTo = filter.CreatedTo.UTC().Format(time.RFC3339Nano)
+	}
+	if filter.CreatedBefore != nil {
+		createdBefore = filter.CreatedBefore.UTC().Format(time.RFC3339Nano)
+	}
+
+	page := fmt.Sprintf("offset=%d", filter.Offset)
+	if filter.CursorMode {
+		page = "cursor="
+		if filter.Cursor != nil {
+			page += model.EncodeCursor(*filter.Cursor)
+		}
+	}
+
+	return fmt.Sprintf("%sprojectId=%d:removed=%s:name=%s:createdFrom=%s:createdTo=%s:createdBefore=%s:sort=%s:%s:limit=%d",
+		cacheName,
+		filter.ProjectID,
+		filter.Removed,
+		url.QueryEscape(filter.Name),
+		createdFrom,
+		createdTo,
+		createdBefore,
+		model.SortSpec(filter.Sort),
+		page,
+		filter.Limit,
+	)
+}
+
+func (r *cacheRepo) AddGood(data *model.Product) {
+	op := "cache repository: creating item"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func AddGood", "data", data)
+
+	ctx, cancel := context.WithTimeout(context.Background(), methodTimer)
+	defer cancel()
+
+	key := fmt.Sprintf("%sid=%d:projectId=%d", cacheItemName, data.ID, data.ProjectID)
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		log.Error("failed to marshal json", "error", err)
+		return
+	}
+
+	if res := r.Client.Set(ctx, key, jsonData, r.TTLKeys); res.Err() != nil {
+		log.Error("failed to add a record", "error", res.Err())
+		return
+	}
+
+	log.Info("successfully added")
+}
+
+func (r *cacheRepo) GetGood(id, projectId int) *model.Product {
+	op := "cache repository: retrieving item"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func GetGood", "id", id, "projectId", projectId)
+
+	ctx, cancel := context.WithTimeout(context.Background(), methodTimer)
+	defer cancel()
+
+	key := fmt.Sprintf("%sid=%d:projectId=%d", cacheItemName, id, projectId)
+
+	result, err := r.Client.Get(ctx, key).Result()
+	switch {
+	case err == nil:
+		var product model.Product
+		if unmarshalErr := json.Unmarshal([]byte(result), &product); unmarshalErr != nil {
+			log.Error("failed to unmarshal data", "error", unmarshalErr)
+			return nil
+		}
+		log.Info("successfully retrieved")
+		return &product
+
+	case strings.Contains(err.Error(), "redis: nil"):
+		log.Warn("data not found")
+		return nil
+
+	default:
+		log.Error("failed to get data from redis", "error", err)
+		return nil
+	}
+}
+
+// InvalidateGoods removes both the cached goods lists and the cached single goods
 func (r *cacheRepo) InvalidateGoods() {
 	op := "cache repository: invalidating"
 	log := r.log.With(slog.String("operation", op))
@@ -96,23 +189,24 @@ func (r *cacheRepo) InvalidateGoods() {
 	ctx, cancel := context.WithTimeout(context.Background(), methodTimer)
 	defer cancel()
 
-	pattern := cacheName + "*"
-
-	iter := r.Client.Scan(ctx, 0, pattern, 0).Iterator()
 	var deleted int
 
-	for iter.Next(ctx) {
-		key := iter.Val()
-		if res := r.Client.Del(ctx, key); res.Err() != nil {
-			log.Error("failed to delete key", "key", key, "error", res.Err())
-			continue
+	for _, pattern := range []string{cacheName + "*", cacheItemName + "*"} {
+		iter := r.Client.Scan(ctx, 0, pattern, 0).Iterator()
+
+		for iter.Next(ctx) {
+			key := iter.Val()
+			if res := r.Client.Del(ctx, key); res.Err() != nil {
+				log.Error("failed to delete key", "key", key, "error", res.Err())
+				continue
+			}
+			deleted++
 		}
-		deleted++
-	}
 
-	if err := iter.Err(); err != nil {
-		log.Error("error during key scanning", "error", err)
-		return
+		if err := iter.Err(); err != nil {
+			log.Error("error during key scanning", "error", err)
+			return
+		}
 	}
 
 	log.Info("successfully invalidated cache", "deletedKeys", deleted)
@@ -2,19 +2,35 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"hezzl/internal/model"
 	"hezzl/pkg/db/postgres"
 	"log/slog"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 const (
 	tableName     = "goods"
 	rollbackTimer = time.Second * 10
+
+	// uniqueViolationCode is the SQLSTATE of a unique constraint violation
+	uniqueViolationCode = "23505"
 )
 
+// queryRower is implemented by both the pool and a transaction
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 type goodsRepo struct {
 	log *slog.Logger
 	*postgres.PostgresDB
@@ -32,29 +48,49 @@ func NewGoodsRepo(deps *GoodsRepoDeps) *goodsRepo {
 	}
 }
 
+// Create appends the good to the end of the project
 func (r *goodsRepo) Create(ctx context.Context, data model.ProductCreateRequest) (*model.Product, error) {
 	op := "goods repository: creating"
 	log := r.log.With(slog.String("operation", op))
 	log.Debug("Call func Create", "data", data)
 
+	ctxRollback, cancel := context.WithTimeout(context.Background(), rollbackTimer)
+	defer cancel()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctxRollback)
+
+	if err := lockProject(ctx, tx, data.ProjectID); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			log.Warn("project not found", "error", err)
+			return nil, err
+		}
+		log.Error("failed to lock project", "error", err)
+		return nil, err
+	}
+
 	var product model.Product
 
 	query := fmt.Sprintf(`
 		INSERT INTO %s (
-			project_id, 
-			name, 
+			project_id,
+			name,
 			priority
 		)
-		SELECT 
-			$1, 
-			$2, 
+		SELECT
+			$1,
+			$2,
 			COALESCE(MAX(priority), 0) + 1
-		FROM goods
+		FROM %s
 		WHERE project_id = $1
-		RETURNING id, project_id, name, description, priority, removed, created_at
-	`, tableName)
+		RETURNING id, project_id, name, description, priority, removed, created_at, version
+	`, tableName, tableName)
 
-	if err := r.DB.QueryRow(ctx, query, data.ProjectID, data.Name).
+	if err := tx.QueryRow(ctx, query, data.ProjectID, data.Name).
 		Scan(
 			&product.ID,
 			&product.ProjectID,
@@ -63,53 +99,86 @@ func (r *goodsRepo) Create(ctx context.Context, data model.ProductCreateRequest)
 			&product.Priority,
 			&product.Removed,
 			&product.CreatedAt,
+			&product.Version,
 		); err != nil {
 		log.Error("failed to create record", "error", err)
 		return nil, err
 	}
 
+	if err := addToOutbox(ctx, tx, model.NewGoodsEvent(ctx, model.EventCreated, nil, &product)); err != nil {
+		log.Error("failed to add event to outbox", "error", err)
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", "error", err)
+		return nil, err
+	}
+
 	log.Info("successfully created")
 	return &product, nil
 }
 
-func (r *goodsRepo) Update(ctx context.Context, data model.ProductUpdateRequest) (*model.Product, error) {
-	op := "goods repository: updating"
+// BulkCreate inserts the goods of one project in a single transaction
+func (r *goodsRepo) BulkCreate(ctx context.Context, projectId int, data []model.ProductCreateRequest) ([]model.Product, error) {
+	op := "goods repository: bulk creating"
 	log := r.log.With(slog.String("operation", op))
-	log.Debug("Call func Update", "data", data)
+	log.Debug("Call func BulkCreate", "projectId", projectId, "count", len(data))
 
 	ctxRollback, cancel := context.WithTimeout(context.Background(), rollbackTimer)
 	defer cancel()
 
-	var product model.Product
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctxRollback)
+
+	if err := lockProject(ctx, tx, projectId); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			log.Warn("project not found", "error", err)
+			return nil, err
+		}
+		log.Error("failed to lock project", "error", err)
+		return nil, err
+	}
+
+	names := make([]string, 0, len(data))
+	for _, el := range data {
+		names = append(names, el.Name)
+	}
 
 	query := fmt.Sprintf(`
-		WITH locked_row AS (
-			SELECT * FROM %s
-			WHERE id = $1 AND project_id = $2
-			FOR UPDATE
-		),
-		updated_row AS (
-			UPDATE %s
-			SET
-				name = $3,
-				description = CASE
-					WHEN $4 <> '' THEN $4
-					ELSE description
-				END
-			WHERE id = $1 AND project_id = $2
-			RETURNING *
+		WITH max_priority AS (
+			SELECT COALESCE(MAX(priority), 0) AS priority
+			FROM %s
+			WHERE project_id = $1
+		)
+		INSERT INTO %s (
+			project_id,
+			name,
+			priority
 		)
-		SELECT * FROM updated_row;
+		SELECT
+			$1,
+			items.name,
+			max_priority.priority + items.ord
+		FROM unnest($2::text[]) WITH ORDINALITY AS items(name, ord), max_priority
+		ORDER BY items.ord
+		RETURNING id, project_id, name, description, priority, removed, created_at, version
 	`, tableName, tableName)
 
-	tx, err := r.DB.Begin(ctx)
+	rows, err := tx.Query(ctx, query, projectId, names)
 	if err != nil {
-		log.Error("failed to begin transaction", "error", err)
+		log.Error("failed to create records", "error", err)
 		return nil, err
 	}
 
-	err = tx.QueryRow(ctx, query, data.ID, data.ProjectID, data.Name, data.Description).
-		Scan(
+	list := make([]model.Product, 0, len(data))
+	for rows.Next() {
+		var product model.Product
+		if err := rows.Scan(
 			&product.ID,
 			&product.ProjectID,
 			&product.Name,
@@ -117,13 +186,68 @@ func (r *goodsRepo) Update(ctx context.Context, data model.ProductUpdateRequest)
 			&product.Priority,
 			&product.Removed,
 			&product.CreatedAt,
-		)
+			&product.Version,
+		); err != nil {
+			rows.Close()
+			log.Error("failed to scan row", "error", err)
+			return nil, err
+		}
+		list = append(list, product)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		log.Error("error while iterating over rows", "error", err)
+		return nil, err
+	}
+
+	// RETURNING does not guarantee the order of rows, priorities follow the order of data
+	slices.SortFunc(list, func(a, b model.Product) int { return a.Priority - b.Priority })
+
+	events := make([]*model.GoodsEvent, 0, len(list))
+	for i := range list {
+		events = append(events, model.NewGoodsEvent(ctx, model.EventCreated, nil, &list[i]))
+	}
+
+	if err := addToOutbox(ctx, tx, events...); err != nil {
+		log.Error("failed to add events to outbox", "error", err)
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", "error", err)
+		return nil, err
+	}
+
+	log.Info("successfully created", "count", len(list))
+	return list, nil
+}
+
+// Update applies the merge patch to the good
+func (r *goodsRepo) Update(ctx context.Context, data model.ProductUpdateRequest) (*model.Product, error) {
+	op := "goods repository: updating"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func Update", "data", data)
+
+	ctxRollback, cancel := context.WithTimeout(context.Background(), rollbackTimer)
+	defer cancel()
 
+	tx, err := r.DB.Begin(ctx)
 	if err != nil {
-		if strings.Contains(err.Error(), "no rows") {
-			log.Warn("record not found", "error", err)
+		log.Error("failed to begin transaction", "error", err)
+		return nil, err
+	}
+
+	product, previous, err := updateGood(ctx, tx, data)
+	if err == nil {
+		err = addToOutbox(ctx, tx, model.NewUpdatedEvent(ctx, previous, product))
+	}
+
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) || errors.Is(err, model.ErrConflict) {
+			log.Warn("failed to update record", "error", err)
 			tx.Rollback(ctxRollback)
-			return nil, model.ErrNotFound
+			return nil, err
 		}
 		log.Error("failed to update record", "error", err)
 		tx.Rollback(ctxRollback)
@@ -136,215 +260,1492 @@ func (r *goodsRepo) Update(ctx context.Context, data model.ProductUpdateRequest)
 	}
 
 	log.Info("successfully updated")
-	return &product, nil
+	return product, nil
 }
 
-func (r *goodsRepo) Remove(ctx context.Context, id, projectId int) (*model.ProductRemoveResponce, error) {
+func (r *goodsRepo) Remove(ctx context.Context, data model.ProductRemoveRequest) (*model.Product, error) {
 	op := "goods repository: removing"
 	log := r.log.With(slog.String("operation", op))
-	log.Debug("Call func Remove", "id", id, "projectId", projectId)
+	log.Debug("Call func Remove", "data", data)
 
-	var result model.ProductRemoveResponce
+	ctxRollback, cancel := context.WithTimeout(context.Background(), rollbackTimer)
+	defer cancel()
 
-	query := fmt.Sprintf(`
-        UPDATE %s
-        SET
-            removed = true
-        WHERE id = $1 AND project_id = $2
-        RETURNING id, project_id, removed
-    `, tableName)
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctxRollback)
 
-	err := r.DB.QueryRow(ctx, query, id, projectId).Scan(
-		&result.ID,
-		&result.ProjectID,
-		&result.Removed,
-	)
+	product, err := removeGood(ctx, tx, data)
+	if err == nil {
+		err = addToOutbox(ctx, tx, model.NewGoodsEvent(ctx, model.EventRemoved, nil, product))
+	}
 
 	if err != nil {
-		if strings.Contains(err.Error(), "no rows") {
-			log.Warn("record not found", "error", err)
-			return nil, model.ErrNotFound
+		if errors.Is(err, model.ErrNotFound) || errors.Is(err, model.ErrConflict) {
+			log.Warn("failed to remove record", "error", err)
+			return nil, err
 		}
 		log.Error("failed to remove record", "error", err)
 		return nil, err
 	}
 
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", "error", err)
+		return nil, err
+	}
+
 	log.Info("successfully removed")
-	return &result, nil
+	return product, nil
 }
 
-func (r *goodsRepo) List(ctx context.Context, offset, limit int) (*model.ProductListResponce, error) {
-	op := "goods repository: goods list retrieval"
+// BulkUpdate updates the goods in one transaction
+func (r *goodsRepo) BulkUpdate(ctx context.Context, data []model.ProductUpdateRequest, atomic bool) ([]model.ProductBulkOutcome, error) {
+	op := "goods repository: bulk updating"
 	log := r.log.With(slog.String("operation", op))
-	log.Debug("Call func List", "offset", offset, "limit", limit)
+	log.Debug("Call func BulkUpdate", "count", len(data), "atomic", atomic)
 
-	var result model.ProductListResponce
+	outcomes, err := r.bulk(ctx, len(data), atomic, func(tx pgx.Tx, i int) (model.ProductBulkOutcome, error) {
+		product, previous, err := updateGood(ctx, tx, data[i])
+		if err == nil {
+			err = addToOutbox(ctx, tx, model.NewUpdatedEvent(ctx, previous, product))
+		}
+		return model.ProductBulkOutcome{Product: product}, err
+	})
+	if err != nil {
+		log.Error("failed to update records", "error", err)
+		return nil, err
+	}
 
-	metaQuery := fmt.Sprintf(`
-		WITH filtered_goods AS (
-			SELECT *
-			FROM %s
-			ORDER BY priority DESC
-			LIMIT $1 OFFSET $2
-		)
-		SELECT
-			(SELECT COUNT(*) FROM %s) AS total,
-			(SELECT COUNT(*) FROM filtered_goods WHERE removed = true) AS removed,
-			$1 AS "limit",
-			$2 + 1 AS "offset";
-    `, tableName, tableName)
+	log.Info("bulk update finished")
+	return outcomes, nil
+}
 
-	if err := r.DB.QueryRow(ctx, metaQuery, limit, offset-1).
-		Scan(
-			&result.Meta.Total,
-			&result.Meta.Removed,
-			&result.Meta.Limit,
-			&result.Meta.Offset,
-		); err != nil {
-		log.Error("failed metaQuery", "error", err)
+// BulkRemove removes the goods in one transaction, the modes are the same as in BulkUpdate
+func (r *goodsRepo) BulkRemove(ctx context.Context, data []model.ProductRemoveRequest, atomic bool) ([]model.ProductBulkOutcome, error) {
+	op := "goods repository: bulk removing"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func BulkRemove", "count", len(data), "atomic", atomic)
+
+	outcomes, err := r.bulk(ctx, len(data), atomic, func(tx pgx.Tx, i int) (model.ProductBulkOutcome, error) {
+		product, err := removeGood(ctx, tx, data[i])
+		if err == nil {
+			err = addToOutbox(ctx, tx, model.NewGoodsEvent(ctx, model.EventRemoved, nil, product))
+		}
+		return model.ProductBulkOutcome{Product: product}, err
+	})
+	if err != nil {
+		log.Error("failed to remove records", "error", err)
 		return nil, err
 	}
 
-	listQuery := fmt.Sprintf(`
-			SELECT id, project_id, name, description, priority, removed, created_at
-			FROM %s
-			ORDER BY priority DESC
-			LIMIT $1 OFFSET $2;
-		`, tableName)
+	log.Info("bulk remove finished")
+	return outcomes, nil
+}
 
-	rows, err := r.DB.Query(ctx, listQuery, limit, offset-1)
+// bulk runs apply for every item index inside one transaction
+func (r *goodsRepo) bulk(
+	ctx context.Context,
+	count int,
+	atomic bool,
+	apply func(tx pgx.Tx, i int) (model.ProductBulkOutcome, error),
+) ([]model.ProductBulkOutcome, error) {
+	ctxRollback, cancel := context.WithTimeout(context.Background(), rollbackTimer)
+	defer cancel()
+
+	tx, err := r.DB.Begin(ctx)
 	if err != nil {
-		log.Error("failed to get goods list", "error", err)
 		return nil, err
 	}
-	defer rows.Close()
+	defer tx.Rollback(ctxRollback)
 
-	list := make([]model.Product, 0, 10)
-	for rows.Next() {
-		var product model.Product
-		if err := rows.Scan(
-			&product.ID,
-			&product.ProjectID,
-			&product.Name,
-			&product.Description,
-			&product.Priority,
-			&product.Removed,
-			&product.CreatedAt,
-		); err != nil {
-			log.Error("failed to scan row", "error", err)
+	outcomes := make([]model.ProductBulkOutcome, count)
+
+	for i := range count {
+		if atomic {
+			outcome, err := apply(tx, i)
+			if err != nil {
+				for j := range outcomes {
+					outcomes[j] = model.ProductBulkOutcome{Err: model.ErrBulkAborted}
+				}
+				outcomes[i].Err = err
+				return outcomes, nil
+			}
+			outcomes[i] = outcome
+			continue
+		}
+
+		savepoint, err := tx.Begin(ctx)
+		if err != nil {
 			return nil, err
 		}
-		list = append(list, product)
-	}
 
-	result.Goods = list
+		outcome, err := apply(savepoint, i)
+		if err != nil {
+			if rollbackErr := savepoint.Rollback(ctx); rollbackErr != nil {
+				return nil, rollbackErr
+			}
+			outcomes[i].Err = err
+			continue
+		}
 
-	if err := rows.Err(); err != nil {
-		log.Error("error while iterating over rows", "error", err)
+		if err := savepoint.Commit(ctx); err != nil {
+			return nil, err
+		}
+		outcomes[i] = outcome
+	}
+
+	if err := tx.Commit(ctx); err != nil {
 		return nil, err
 	}
 
-	log.Info("successful search")
-	return &result, nil
+	return outcomes, nil
 }
 
-func (r *goodsRepo) Reprioritizy(ctx context.Context, data model.ProductReprioritizyRequest) (*model.ProductReprioritizyResponce, error) {
-	op := "goods service: reprioritizing"
-	log := r.log.With(slog.String("operation", op))
-	log.Debug("Call func Reprioritizy", "data", data)
-
-	var result model.ProductReprioritizyResponce
-
-	queryMaxPriority := fmt.Sprintf(`
-		SELECT MAX(priority)
+// lockProject locks the project row until the end of the transaction
+func lockProject(ctx context.Context, q queryRower, projectId int) error {
+	query := fmt.Sprintf(`
+		SELECT id
 		FROM %s
-		WHERE project_id = $1
-    `, tableName)
-
-	var maxPriority int
-	err := r.DB.QueryRow(ctx, queryMaxPriority, data.ProjectID).Scan(&maxPriority)
-	if err != nil {
-		log.Error("failed to get max priority", "error", err)
-		return nil, err
-	}
+		WHERE id = $1
+		FOR UPDATE
+	`, projectsTableName)
 
-	if data.NewPriority > maxPriority {
-		log.Warn("new priority is higher than current max")
-		return nil, model.ErrMaxPriority
+	var lockedId int
+	if err := q.QueryRow(ctx, query, projectId).Scan(&lockedId); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return model.ErrNotFound
+		}
+		return err
 	}
 
-	queryCurrentPriority := fmt.Sprintf(`
-        SELECT priority
-        FROM %s
-        WHERE id = $1 AND project_id = $2
-    `, tableName)
+	return nil
+}
 
-    var currentPriority int
-    err = r.DB.QueryRow(ctx, queryCurrentPriority, data.ID, data.ProjectID).Scan(&currentPriority)
-    if err != nil {
-        log.Error("failed to get current priority", "error", err)
-        return nil, err
-    }
+// isUniqueViolation tells whether the query failed on a unique constraint
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode
+}
 
-	if currentPriority == data.NewPriority {
-		log.Warn("new priority, equal to current priority")
-		return nil, model.ErrCurrentPriority
+// missingOrConflict tells whether the good is missing or has another version
+func missingOrConflict(ctx context.Context, q queryRower, id, projectId int, version *int) error {
+	if version == nil {
+		return model.ErrNotFound
 	}
 
 	query := fmt.Sprintf(`
-		WITH target AS (
-			SELECT id, priority
+		SELECT EXISTS (
+			SELECT 1
 			FROM %s
 			WHERE id = $1 AND project_id = $2
-		),
-		new_priority_item AS (
-			SELECT id, priority
-			FROM %s
-			WHERE project_id = $2 AND priority = $3 AND id != (SELECT id FROM target)
 		)
-		UPDATE %s g
-		SET priority = CASE
-			WHEN g.id = (SELECT id FROM target) THEN (SELECT priority FROM new_priority_item)
-			WHEN g.id = (SELECT id FROM new_priority_item) THEN (SELECT priority FROM target)
-			ELSE g.priority
-		END
-		FROM target, new_priority_item
-		WHERE g.id IN (target.id, new_priority_item.id)
-		RETURNING g.id, g.project_id, g.priority
-    `, tableName, tableName, tableName)
+	`, tableName)
 
-	rows, err := r.DB.Query(ctx, query, data.ID, data.ProjectID, data.NewPriority)
-	if err != nil {
-		log.Error("failed to execute query", "error", err)
-		return nil, err
+	var exists bool
+	if err := q.QueryRow(ctx, query, id, projectId).Scan(&exists); err != nil {
+		return err
 	}
-	defer rows.Close()
 
-	found := false
-	for rows.Next() {
-		var item struct {
-			ID        int `json:"id"`
-			ProjectID int `json:"project_id"`
-			Priority  int `json:"priority"`
-		}
-		if err := rows.Scan(&item.ID, &item.ProjectID, &item.Priority); err != nil {
-			log.Error("failed to scan row", "error", err)
-			return nil, err
-		}
-		result.Priorities = append(result.Priorities, item)
-		found = true
+	if exists {
+		return model.ErrConflict
 	}
 
-	if err := rows.Err(); err != nil {
-		log.Error("error while iterating over rows", "error", err)
-		return nil, err
-	}
+	return model.ErrNotFound
+}
 
-	if !found {
-		log.Warn("records not found")
-		return nil, model.ErrNotFound
+// notRestored tells why the restore of the good matched no row
+func notRestored(ctx context.Context, q queryRower, data model.ProductRestoreRequest) error {
+	query := fmt.Sprintf(`
+		SELECT removed, version
+		FROM %s
+		WHERE id = $1 AND project_id = $2
+	`, tableName)
+
+	var (
+		removed bool
+		version int
+	)
+	if err := q.QueryRow(ctx, query, data.ID, data.ProjectID).Scan(&removed, &version); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return model.ErrNotFound
+		}
+		return err
+	}
+
+	if data.Version != nil && *data.Version != version {
+		return model.ErrConflict
+	}
+
+	if !removed {
+		return model.ErrNotRemoved
+	}
+
+	return model.ErrConflict
+}
+
+// updateGood applies the merge patch and returns the good and its previous state
+func updateGood(ctx context.Context, q queryRower, data model.ProductUpdateRequest) (*model.Product, *model.Product, error) {
+	var product model.Product
+	var previous model.Product
+
+	query := fmt.Sprintf(`
+		WITH locked_row AS (
+			SELECT * FROM %s
+			WHERE id = $1 AND project_id = $2
+			FOR UPDATE
+		),
+		updated_row AS (
+			UPDATE %s
+			SET
+				name = CASE WHEN $3::boolean THEN $4 ELSE name END,
+				description = CASE WHEN $5::boolean THEN $6 ELSE description END,
+				version = version + 1
+			WHERE id = $1 AND project_id = $2 AND ($7::int IS NULL OR version = $7)
+			RETURNING id, project_id, name, description, priority, removed, created_at, version
+		)
+		SELECT u.*, l.name, l.description, l.version
+		FROM updated_row u
+		JOIN locked_row l ON l.id = u.id;
+	`, tableName, tableName)
+
+	err := q.QueryRow(
+		ctx,
+		query,
+		data.ID,
+		data.ProjectID,
+		data.Name.Set,
+		data.Name.Value,
+		data.Description.Set,
+		data.Description.Value,
+		data.Version,
+	).
+		Scan(
+			&product.ID,
+			&product.ProjectID,
+			&product.Name,
+			&product.Description,
+			&product.Priority,
+			&product.Removed,
+			&product.CreatedAt,
+			&product.Version,
+			&previous.Name,
+			&previous.Description,
+			&previous.Version,
+		)
+
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, nil, missingOrConflict(ctx, q, data.ID, data.ProjectID, data.Version)
+		}
+		return nil, nil, err
+	}
+
+	previous.ID = product.ID
+	previous.ProjectID = product.ProjectID
+	previous.Priority = product.Priority
+	previous.Removed = product.Removed
+	previous.CreatedAt = product.CreatedAt
+
+	return &product, &previous, nil
+}
+
+// removeGood marks the good as removed, q is either the pool or a transaction
+func removeGood(ctx context.Context, q queryRower, data model.ProductRemoveRequest) (*model.Product, error) {
+	var product model.Product
+
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET
+			removed = true,
+			removed_at = CASE WHEN removed THEN removed_at ELSE CURRENT_TIMESTAMP END,
+			version = version + 1
+		WHERE id = $1 AND project_id = $2 AND ($3::int IS NULL OR version = $3)
+		RETURNING id, project_id, name, description, priority, removed, created_at, version
+	`, tableName)
+
+	err := q.QueryRow(ctx, query, data.ID, data.ProjectID, data.Version).Scan(
+		&product.ID,
+		&product.ProjectID,
+		&product.Name,
+		&product.Description,
+		&product.Priority,
+		&product.Removed,
+		&product.CreatedAt,
+		&product.Version,
+	)
+
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, missingOrConflict(ctx, q, data.ID, data.ProjectID, data.Version)
+		}
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+// Restore restores the removed good, a good that is not removed gives model.ErrNotRemoved
+func (r *goodsRepo) Restore(ctx context.Context, data model.ProductRestoreRequest) (*model.Product, error) {
+	op := "goods repository: restoring"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func Restore", "data", data)
+
+	ctxRollback, cancel := context.WithTimeout(context.Background(), rollbackTimer)
+	defer cancel()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctxRollback)
+
+	var product model.Product
+
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET
+			removed = false,
+			removed_at = NULL,
+			version = version + 1
+		WHERE id = $1 AND project_id = $2 AND removed AND ($3::int IS NULL OR version = $3)
+		RETURNING id, project_id, name, description, priority, removed, created_at, version
+	`, tableName)
+
+	err = tx.QueryRow(ctx, query, data.ID, data.ProjectID, data.Version).
+		Scan(
+			&product.ID,
+			&product.ProjectID,
+			&product.Name,
+			&product.Description,
+			&product.Priority,
+			&product.Removed,
+			&product.CreatedAt,
+			&product.Version,
+		)
+
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			err = notRestored(ctx, tx, data)
+			log.Warn("failed to restore record", "error", err)
+			return nil, err
+		}
+		log.Error("failed to restore record", "error", err)
+		return nil, err
+	}
+
+	if err := addToOutbox(ctx, tx, model.NewGoodsEvent(ctx, model.EventRestored, nil, &product)); err != nil {
+		log.Error("failed to add event to outbox", "error", err)
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", "error", err)
+		return nil, err
+	}
+
+	log.Info("successfully restored")
+	return &product, nil
+}
+
+// Purge deletes goods removed before removedBefore and renumbers their projects
+func (r *goodsRepo) Purge(ctx context.Context, removedBefore time.Time, projectId int) ([]model.Product, error) {
+	op := "goods repository: purging"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func Purge", "removedBefore", removedBefore, "projectId", projectId)
+
+	ctxRollback, cancel := context.WithTimeout(context.Background(), rollbackTimer)
+	defer cancel()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctxRollback)
+
+	projectIds, err := purgedProjects(ctx, tx, removedBefore, projectId)
+	if err != nil {
+		log.Error("failed to get projects to purge", "error", err)
+		return nil, err
+	}
+
+	if len(projectIds) == 0 {
+		log.Info("nothing to purge")
+		return []model.Product{}, nil
+	}
+
+	// the projects are locked in the order of their ids, so concurrent purges do not deadlock
+	for _, id := range projectIds {
+		if err := lockProject(ctx, tx, id); err != nil {
+			log.Error("failed to lock project", "projectId", id, "error", err)
+			return nil, err
+		}
+	}
+
+	query := fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE removed = true
+			AND removed_at < $1
+			AND project_id = ANY($2)
+		RETURNING id, project_id, name, description, priority, removed, created_at, version
+	`, tableName)
+
+	rows, err := tx.Query(ctx, query, removedBefore, projectIds)
+	if err != nil {
+		log.Error("failed to purge records", "error", err)
+		return nil, err
+	}
+
+	list := make([]model.Product, 0, 10)
+	for rows.Next() {
+		var product model.Product
+		if err := rows.Scan(
+			&product.ID,
+			&product.ProjectID,
+			&product.Name,
+			&product.Description,
+			&product.Priority,
+			&product.Removed,
+			&product.CreatedAt,
+			&product.Version,
+		); err != nil {
+			rows.Close()
+			log.Error("failed to scan row", "error", err)
+			return nil, err
+		}
+		list = append(list, product)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		log.Error("error while iterating over rows", "error", err)
+		return nil, err
+	}
+
+	events := make([]*model.GoodsEvent, 0, len(list)+len(projectIds))
+	for i := range list {
+		events = append(events, model.NewGoodsEvent(ctx, model.EventPurged, &list[i], nil))
+	}
+
+	for _, id := range projectIds {
+		changed, err := renumberPriorities(ctx, tx, id)
+		if err != nil {
+			log.Error("failed to renumber priorities", "projectId", id, "error", err)
+			return nil, err
+		}
+
+		if len(changed) > 0 {
+			events = append(events, model.NewPrioritiesEvent(ctx, model.EventReordered, id, changed))
+		}
+	}
+
+	if err := addToOutbox(ctx, tx, events...); err != nil {
+		log.Error("failed to add events to outbox", "error", err)
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", "error", err)
+		return nil, err
+	}
+
+	log.Info("successfully purged", "purged", len(list))
+	return list, nil
+}
+
+// purgedProjects returns the ids of the projects having goods removed before removedBefore
+func purgedProjects(ctx context.Context, tx pgx.Tx, removedBefore time.Time, projectId int) ([]int, error) {
+	query := fmt.Sprintf(`
+		SELECT DISTINCT project_id
+		FROM %s
+		WHERE removed = true
+			AND removed_at < $1
+			AND ($2::int = 0 OR project_id = $2::int)
+		ORDER BY project_id
+	`, tableName)
+
+	rows, err := tx.Query(ctx, query, removedBefore, projectId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projectIds := make([]int, 0, 10)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		projectIds = append(projectIds, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return projectIds, nil
+}
+
+func (r *goodsRepo) Get(ctx context.Context, id, projectId int) (*model.Product, error) {
+	op := "goods repository: retrieving"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func Get", "id", id, "projectId", projectId)
+
+	var product model.Product
+
+	query := fmt.Sprintf(`
+		SELECT id, project_id, name, description, priority, removed, created_at, version
+		FROM %s
+		WHERE id = $1 AND project_id = $2
+	`, tableName)
+
+	err := r.DB.QueryRow(ctx, query, id, projectId).
+		Scan(
+			&product.ID,
+			&product.ProjectID,
+			&product.Name,
+			&product.Description,
+			&product.Priority,
+			&product.Removed,
+			&product.CreatedAt,
+			&product.Version,
+		)
+
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			log.Warn("record not found", "error", err)
+			return nil, model.ErrNotFound
+		}
+		log.Error("failed to get record", "error", err)
+		return nil, err
+	}
+
+	log.Info("successfully retrieved")
+	return &product, nil
+}
+
+// CountForReplay returns the number of goods selected by the filter
+func (r *goodsRepo) CountForReplay(ctx context.Context, filter model.ReplayFilter) (int, error) {
+	op := "goods repository: counting for replay"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func CountForReplay", "filter", filter)
+
+	var count int
+
+	query := fmt.Sprintf(`
+		SELECT COUNT(*)
+		FROM %s
+		WHERE ($1 = 0 OR project_id = $1)
+			AND ($2::timestamp IS NULL OR created_at >= $2)
+			AND ($3::timestamp IS NULL OR created_at < $3)
+	`, tableName)
+
+	if err := r.DB.QueryRow(ctx, query, filter.ProjectID, filter.From, filter.To).Scan(&count); err != nil {
+		log.Error("failed to count records", "error", err)
+		return 0, err
+	}
+
+	log.Info("successfully counted", "count", count)
+	return count, nil
+}
+
+// ListForReplay returns up to limit goods with ids greater than afterId
+func (r *goodsRepo) ListForReplay(ctx context.Context, filter model.ReplayFilter, afterId, limit int) ([]model.Product, error) {
+	op := "goods repository: listing for replay"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func ListForReplay", "filter", filter, "afterId", afterId, "limit", limit)
+
+	query := fmt.Sprintf(`
+		SELECT id, project_id, name, description, priority, removed, created_at, version
+		FROM %s
+		WHERE id > $1
+			AND ($2 = 0 OR project_id = $2)
+			AND ($3::timestamp IS NULL OR created_at >= $3)
+			AND ($4::timestamp IS NULL OR created_at < $4)
+		ORDER BY id
+		LIMIT $5
+	`, tableName)
+
+	rows, err := r.DB.Query(ctx, query, afterId, filter.ProjectID, filter.From, filter.To, limit)
+	if err != nil {
+		log.Error("failed to get goods", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]model.Product, 0, limit)
+	for rows.Next() {
+		var product model.Product
+		if err := rows.Scan(
+			&product.ID,
+			&product.ProjectID,
+			&product.Name,
+			&product.Description,
+			&product.Priority,
+			&product.Removed,
+			&product.CreatedAt,
+			&product.Version,
+		); err != nil {
+			log.Error("failed to scan row", "error", err)
+			return nil, err
+		}
+		list = append(list, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error("error while iterating over rows", "error", err)
+		return nil, err
+	}
+
+	log.Info("successful search", "count", len(list))
+	return list, nil
+}
+
+func (r *goodsRepo) List(ctx context.Context, filter model.ProductListFilter) (*model.ProductListResponce, error) {
+	op := "goods repository: goods list retrieval"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func List", "filter", filter)
+
+	if filter.CursorMode {
+		return r.listByCursor(ctx, filter)
+	}
+
+	var result model.ProductListResponce
+
+	where, args := goodsListWhere(filter)
+	limitArg := len(args) + 1
+	offsetArg := len(args) + 2
+	args = append(args, filter.Limit, filter.Offset-1)
+
+	metaQuery := fmt.Sprintf(`
+		SELECT
+			COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE removed = true) AS removed,
+			$%d::int AS "limit",
+			$%d::int + 1 AS "offset"
+		FROM %s
+		%s;
+	`, limitArg, offsetArg, tableName, where)
+
+	if err := r.DB.QueryRow(ctx, metaQuery, args...).
+		Scan(
+			&result.Meta.Total,
+			&result.Meta.Removed,
+			&result.Meta.Limit,
+			&result.Meta.Offset,
+		); err != nil {
+		log.Error("failed metaQuery", "error", err)
+		return nil, err
+	}
+
+	listQuery := fmt.Sprintf(`
+			SELECT id, project_id, name, description, priority, removed, created_at, version
+			FROM %s
+			%s
+			ORDER BY %s
+			LIMIT $%d OFFSET $%d;
+		`, tableName, where, goodsOrderBy(filter.Sort, false), limitArg, offsetArg)
+
+	rows, err := r.DB.Query(ctx, listQuery, args...)
+	if err != nil {
+		log.Error("failed to get goods list", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]model.Product, 0, 10)
+	for rows.Next() {
+		var product model.Product
+		if err := rows.Scan(
+			&product.ID,
+			&product.ProjectID,
+			&product.Name,
+			&product.Description,
+			&product.Priority,
+			&product.Removed,
+			&product.CreatedAt,
+			&product.Version,
+		); err != nil {
+			log.Error("failed to scan row", "error", err)
+			return nil, err
+		}
+		list = append(list, product)
+	}
+
+	result.Goods = list
+
+	if err := rows.Err(); err != nil {
+		log.Error("error while iterating over rows", "error", err)
+		return nil, err
+	}
+
+	log.Info("successful search")
+	return &result, nil
+}
+
+// listByCursor returns the page of goods that follows or precedes filter.Cursor
+func (r *goodsRepo) listByCursor(ctx context.Context, filter model.ProductListFilter) (*model.ProductListResponce, error) {
+	op := "goods repository: goods list retrieval by cursor"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func listByCursor", "filter", filter)
+
+	var result model.ProductListResponce
+
+	where, args := goodsListWhere(filter)
+
+	metaQuery := fmt.Sprintf(`
+		SELECT
+			COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE removed = true) AS removed
+		FROM %s
+		%s;
+	`, tableName, where)
+
+	if err := r.DB.QueryRow(ctx, metaQuery, args...).
+		Scan(
+			&result.Meta.Total,
+			&result.Meta.Removed,
+		); err != nil {
+		log.Error("failed metaQuery", "error", err)
+		return nil, err
+	}
+
+	result.Meta.Limit = filter.Limit
+
+	backward := filter.Cursor != nil && filter.Cursor.Backward
+
+	if filter.Cursor != nil {
+		var cursorCondition string
+		cursorCondition, args = goodsKeyset(filter.Sort, *filter.Cursor, args)
+
+		if where == "" {
+			where = "WHERE " + cursorCondition
+		} else {
+			where += " AND " + cursorCondition
+		}
+	}
+
+	// one extra row tells whether there is a page after this one
+	args = append(args, filter.Limit+1)
+
+	listQuery := fmt.Sprintf(`
+			SELECT id, project_id, name, description, priority, removed, created_at, version
+			FROM %s
+			%s
+			ORDER BY %s
+			LIMIT $%d;
+		`, tableName, where, goodsOrderBy(filter.Sort, backward), len(args))
+
+	rows, err := r.DB.Query(ctx, listQuery, args...)
+	if err != nil {
+		log.Error("failed to get goods list", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]model.Product, 0, filter.Limit+1)
+	for rows.Next() {
+		var product model.Product
+		if err := rows.Scan(
+			&product.ID,
+			&product.ProjectID,
+			&product.Name,
+			&product.Description,
+			&product.Priority,
+			&product.Removed,
+			&product.CreatedAt,
+			&product.Version,
+		); err != nil {
+			log.Error("failed to scan row", "error", err)
+			return nil, err
+		}
+		list = append(list, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error("error while iterating over rows", "error", err)
+		return nil, err
+	}
+
+	hasMore := len(list) > filter.Limit
+	if hasMore {
+		list = list[:filter.Limit]
+	}
+
+	if backward {
+		slices.Reverse(list)
+	}
+
+	if len(list) > 0 {
+		first, last := list[0], list[len(list)-1]
+
+		// a backward page always has the next page the cursor came from
+		if (backward && hasMore) || (!backward && filter.Cursor != nil) {
+			result.Meta.PrevCursor = model.EncodeCursor(goodsCursor(filter.Sort, first, true))
+		}
+
+		if (!backward && hasMore) || backward {
+			result.Meta.NextCursor = model.EncodeCursor(goodsCursor(filter.Sort, last, false))
+		}
+	}
+
+	result.Goods = list
+
+	log.Info("successful search")
+	return &result, nil
+}
+
+// sortColumnTypes maps the sortable fields to their column types, cursor values are cast to them
+var sortColumnTypes = map[string]string{
+	"id":          "integer",
+	"project_id":  "integer",
+	"name":        "text",
+	"description": "text",
+	"priority":    "integer",
+	"removed":     "boolean",
+	"created_at":  "timestamp",
+}
+
+// sortTiebreakerDesc tells the direction of the id tiebreaker, it follows the last sort field
+func sortTiebreakerDesc(sort []model.SortField) bool {
+	if len(sort) == 0 {
+		return true
+	}
+	return sort[len(sort)-1].Desc
+}
+
+// goodsOrderBy builds the ORDER BY list for the sort, reverse flips every direction
+func goodsOrderBy(sort []model.SortField, reverse bool) string {
+	direction := func(desc bool) string {
+		if desc != reverse {
+			return "DESC"
+		}
+		return "ASC"
+	}
+
+	parts := make([]string, 0, len(sort)+1)
+	for _, field := range sort {
+		parts = append(parts, field.Field+" "+direction(field.Desc))
+	}
+	parts = append(parts, "id "+direction(sortTiebreakerDesc(sort)))
+
+	return strings.Join(parts, ", ")
+}
+
+// goodsKeyset builds the condition selecting rows after the cursor in the sort order
+func goodsKeyset(sort []model.SortField, cursor model.ProductCursor, args []any) (string, []any) {
+	columns := make([]string, 0, len(sort)+1)
+	descs := make([]bool, 0, len(sort)+1)
+	placeholders := make([]string, 0, len(sort)+1)
+
+	for i, field := range sort {
+		args = append(args, cursor.Values[i])
+		columns = append(columns, field.Field)
+		descs = append(descs, field.Desc)
+		placeholders = append(placeholders, fmt.Sprintf("$%d::text::%s", len(args), sortColumnTypes[field.Field]))
+	}
+
+	args = append(args, cursor.ID)
+	columns = append(columns, "id")
+	descs = append(descs, sortTiebreakerDesc(sort))
+	placeholders = append(placeholders, fmt.Sprintf("$%d::integer", len(args)))
+
+	alternatives := make([]string, 0, len(columns))
+	for i := range columns {
+		terms := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			terms = append(terms, columns[j]+" = "+placeholders[j])
+		}
+
+		comparison := ">"
+		if descs[i] != cursor.Backward {
+			comparison = "<"
+		}
+		terms = append(terms, columns[i]+" "+comparison+" "+placeholders[i])
+
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+// goodsCursor builds the cursor pointing at the product for the given sort
+func goodsCursor(sort []model.SortField, product model.Product, backward bool) model.ProductCursor {
+	values := make([]string, 0, len(sort))
+	for _, field := range sort {
+		var value string
+		switch field.Field {
+		case "id":
+			value = strconv.Itoa(product.ID)
+		case "project_id":
+			value = strconv.Itoa(product.ProjectID)
+		case "name":
+			value = product.Name
+		case "description":
+			value = product.Description
+		case "priority":
+			value = strconv.Itoa(product.Priority)
+		case "removed":
+			value = strconv.FormatBool(product.Removed)
+		case "created_at":
+			value = product.CreatedAt.Format("2006-01-02T15:04:05.999999")
+		}
+		values = append(values, value)
+	}
+
+	return model.ProductCursor{
+		Sort:     model.SortSpec(sort),
+		Values:   values,
+		ID:       product.ID,
+		Backward: backward,
+	}
+}
+
+// goodsListWhere builds the WHERE clause of the goods list and its positional arguments
+func goodsListWhere(filter model.ProductListFilter) (string, []any) {
+	conditions := make([]string, 0, 5)
+	args := make([]any, 0, 5)
+
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ProjectID != 0 {
+		addCondition("project_id = $%d", filter.ProjectID)
+	}
+
+	switch filter.Removed {
+	case model.RemovedFilterTrue:
+		conditions = append(conditions, "removed = true")
+	case model.RemovedFilterFalse:
+		conditions = append(conditions, "removed = false")
+	}
+
+	if filter.Name != "" {
+		addCondition(`name LIKE $%d || '%%'`, likeEscaper.Replace(filter.Name))
+	}
+
+	if filter.CreatedFrom != nil {
+		addCondition("created_at >= $%d", *filter.CreatedFrom)
+	}
+
+	if filter.CreatedTo != nil {
+		addCondition("created_at <= $%d", *filter.CreatedTo)
+	}
+
+	if filter.CreatedBefore != nil {
+		addCondition("created_at < $%d", *filter.CreatedBefore)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (r *goodsRepo) Reprioritizy(ctx context.Context, data model.ProductReprioritizyRequest) (*model.ProductReprioritizyResponce, error) {
+	op := "goods service: reprioritizing"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func Reprioritizy", "data", data)
+
+	if data.Mode == model.ReprioritizyModeMove || data.IsRelative() {
+		return r.movePriority(ctx, data)
+	}
+
+	ctxRollback, cancel := context.WithTimeout(context.Background(), rollbackTimer)
+	defer cancel()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctxRollback)
+
+	if err := lockProject(ctx, tx, data.ProjectID); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			log.Warn("project not found", "error", err)
+			return nil, err
+		}
+		log.Error("failed to lock project", "error", err)
+		return nil, err
+	}
+
+	var result model.ProductReprioritizyResponce
+
+	queryMaxPriority := fmt.Sprintf(`
+		SELECT MAX(priority)
+		FROM %s
+		WHERE project_id = $1
+    `, tableName)
+
+	var maxPriority *int
+	err = tx.QueryRow(ctx, queryMaxPriority, data.ProjectID).Scan(&maxPriority)
+	if err != nil {
+		log.Error("failed to get max priority", "error", err)
+		return nil, err
+	}
+
+	if maxPriority == nil {
+		log.Warn("project has no goods")
+		return nil, model.ErrNotFound
+	}
+
+	if data.NewPriority > *maxPriority {
+		log.Warn("new priority is higher than current max")
+		return nil, model.ErrMaxPriority
+	}
+
+	queryCurrentPriority := fmt.Sprintf(`
+        SELECT priority, version
+        FROM %s
+        WHERE id = $1 AND project_id = $2
+    `, tableName)
+
+	var currentPriority, version int
+	err = tx.QueryRow(ctx, queryCurrentPriority, data.ID, data.ProjectID).Scan(&currentPriority, &version)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			log.Warn("record not found")
+			return nil, model.ErrNotFound
+		}
+		log.Error("failed to get current priority", "error", err)
+		return nil, err
+	}
+
+	if data.Version != nil && *data.Version != version {
+		log.Warn("version mismatch", "version", version, "expected", *data.Version)
+		return nil, model.ErrConflict
+	}
+
+	if currentPriority == data.NewPriority {
+		log.Warn("new priority, equal to current priority")
+		return nil, model.ErrCurrentPriority
+	}
+
+	query := fmt.Sprintf(`
+		WITH target AS (
+			SELECT id, priority
+			FROM %s
+			WHERE id = $1 AND project_id = $2 AND ($4::int IS NULL OR version = $4)
+		),
+		new_priority_item AS (
+			SELECT id, priority
+			FROM %s
+			WHERE project_id = $2 AND priority = $3 AND id != (SELECT id FROM target)
+		)
+		UPDATE %s g
+		SET priority = CASE
+			WHEN g.id = (SELECT id FROM target) THEN (SELECT priority FROM new_priority_item)
+			WHEN g.id = (SELECT id FROM new_priority_item) THEN (SELECT priority FROM target)
+			ELSE g.priority
+		END,
+		version = g.version + 1
+		FROM target, new_priority_item
+		WHERE g.id IN (target.id, new_priority_item.id)
+		RETURNING g.id, g.project_id, g.name, g.description, g.priority, g.removed, g.created_at, g.version
+    `, tableName, tableName, tableName)
+
+	rows, err := tx.Query(ctx, query, data.ID, data.ProjectID, data.NewPriority, data.Version)
+	if err != nil {
+		log.Error("failed to execute query", "error", err)
+		return nil, err
+	}
+
+	goods, err := scanGoods(rows)
+	if err != nil {
+		if isUniqueViolation(err) {
+			log.Warn("priority is already taken", "error", err)
+			return nil, model.ErrConflict
+		}
+		log.Error("failed to read swapped records", "error", err)
+		return nil, err
+	}
+
+	if len(goods) == 0 {
+		err := missingOrConflict(ctx, tx, data.ID, data.ProjectID, data.Version)
+		log.Warn("records not found", "error", err)
+		return nil, err
+	}
+	result.Priorities = model.ProductPriorities(goods)
+
+	event := model.NewPrioritiesEvent(ctx, model.EventReprioritized, data.ProjectID, goods)
+	if err := addToOutbox(ctx, tx, event); err != nil {
+		log.Error("failed to add event to outbox", "error", err)
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", "error", err)
+		return nil, err
 	}
 
 	log.Info("successfully reprioritized")
 	return &result, nil
 }
+
+// movePriority moves the good to the new priority keeping the order of the rest
+func (r *goodsRepo) movePriority(ctx context.Context, data model.ProductReprioritizyRequest) (*model.ProductReprioritizyResponce, error) {
+	op := "goods repository: moving priority"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func movePriority", "data", data)
+
+	ctxRollback, cancel := context.WithTimeout(context.Background(), rollbackTimer)
+	defer cancel()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctxRollback)
+
+	if err := lockProject(ctx, tx, data.ProjectID); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			log.Warn("project not found", "error", err)
+			return nil, err
+		}
+		log.Error("failed to lock project", "error", err)
+		return nil, err
+	}
+
+	newPriority := data.NewPriority
+	if data.IsRelative() {
+		newPriority, err = relativePriority(ctx, tx, data)
+	}
+
+	var goods []model.Product
+	if err == nil {
+		goods, err = shiftPriority(ctx, tx, data.ID, data.ProjectID, newPriority, data.Version)
+	}
+
+	if err == nil {
+		err = addToOutbox(ctx, tx, model.NewPrioritiesEvent(ctx, model.EventReprioritized, data.ProjectID, goods))
+	}
+
+	if isUniqueViolation(err) {
+		err = model.ErrConflict
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrNotFound),
+			errors.Is(err, model.ErrConflict),
+			errors.Is(err, model.ErrMaxPriority),
+			errors.Is(err, model.ErrCurrentPriority),
+			errors.Is(err, model.ErrAnchorNotFound),
+			errors.Is(err, model.ErrAnchorOtherProject),
+			errors.Is(err, model.ErrAnchorRemoved):
+			log.Warn("failed to move priority", "error", err)
+		default:
+			log.Error("failed to move priority", "error", err)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", "error", err)
+		return nil, err
+	}
+
+	log.Info("successfully moved", "changed", len(goods))
+	return &model.ProductReprioritizyResponce{Priorities: model.ProductPriorities(goods)}, nil
+}
+
+// shiftPriority does the work of movePriority inside a locked transaction
+func shiftPriority(ctx context.Context, tx pgx.Tx, id, projectId, newPriority int, version *int) ([]model.Product, error) {
+	var currentPriority, currentVersion, maxPriority int
+
+	query := fmt.Sprintf(`
+		SELECT
+			priority,
+			version,
+			(SELECT MAX(priority) FROM %s WHERE project_id = $2)
+		FROM %s
+		WHERE id = $1 AND project_id = $2
+	`, tableName, tableName)
+
+	if err := tx.QueryRow(ctx, query, id, projectId).Scan(&currentPriority, &currentVersion, &maxPriority); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, model.ErrNotFound
+		}
+		return nil, err
+	}
+
+	switch {
+	case version != nil && *version != currentVersion:
+		return nil, model.ErrConflict
+	case newPriority > maxPriority:
+		return nil, model.ErrMaxPriority
+	case newPriority == currentPriority:
+		return nil, model.ErrCurrentPriority
+	}
+
+	// the goods in between move by one towards the place the target has left
+	shiftQuery := fmt.Sprintf(`
+		UPDATE %s
+		SET priority = CASE
+			WHEN id = $1 THEN $3::int
+			WHEN $3::int > $4::int THEN priority - 1
+			ELSE priority + 1
+		END,
+		version = version + 1
+		WHERE project_id = $2
+			AND (
+				id = $1
+				OR priority BETWEEN LEAST($3::int, $4::int) AND GREATEST($3::int, $4::int)
+			)
+		RETURNING id, project_id, name, description, priority, removed, created_at, version
+	`, tableName)
+
+	rows, err := tx.Query(ctx, shiftQuery, id, projectId, newPriority, currentPriority)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanGoods(rows)
+}
+
+// relativePriority resolves the priority the good has to be moved to
+func relativePriority(ctx context.Context, tx pgx.Tx, data model.ProductReprioritizyRequest) (int, error) {
+	if data.Position != "" {
+		aggregate := "MAX"
+		if data.Position == model.PositionBottom {
+			aggregate = "MIN"
+		}
+
+		query := fmt.Sprintf(`
+			SELECT %s(priority)
+			FROM %s
+			WHERE project_id = $1
+		`, aggregate, tableName)
+
+		var priority *int
+		if err := tx.QueryRow(ctx, query, data.ProjectID).Scan(&priority); err != nil {
+			return 0, err
+		}
+
+		if priority == nil {
+			return 0, model.ErrNotFound
+		}
+
+		return *priority, nil
+	}
+
+	anchorId := data.Before
+	if anchorId == nil {
+		anchorId = data.After
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			anchor.project_id,
+			anchor.removed,
+			anchor.priority,
+			(SELECT priority FROM %s WHERE id = $2 AND project_id = $3)
+		FROM %s anchor
+		WHERE anchor.id = $1
+	`, tableName, tableName)
+
+	var (
+		anchorProjectId, anchorPriority int
+		anchorRemoved                   bool
+		currentPriority                 *int
+	)
+
+	if err := tx.QueryRow(ctx, query, *anchorId, data.ID, data.ProjectID).
+		Scan(&anchorProjectId, &anchorRemoved, &anchorPriority, &currentPriority); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return 0, model.ErrAnchorNotFound
+		}
+		return 0, err
+	}
+
+	switch {
+	case anchorProjectId != data.ProjectID:
+		return 0, model.ErrAnchorOtherProject
+	case anchorRemoved:
+		return 0, model.ErrAnchorRemoved
+	case currentPriority == nil:
+		return 0, model.ErrNotFound
+	}
+
+	// moving down the good takes the place of the anchor
+	movingUp := *currentPriority < anchorPriority
+
+	switch {
+	case data.Before != nil && movingUp:
+		return anchorPriority, nil
+	case data.Before != nil:
+		return anchorPriority + 1, nil
+	case movingUp:
+		return anchorPriority - 1, nil
+	default:
+		return anchorPriority, nil
+	}
+}
+
+// CheckPriorities reports duplicated priorities and gaps, a zero projectId checks every project
+func (r *goodsRepo) CheckPriorities(ctx context.Context, projectId int) ([]model.PriorityReport, error) {
+	op := "goods repository: checking priorities"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func CheckPriorities", "projectId", projectId)
+
+	query := fmt.Sprintf(`
+		SELECT
+			project_id,
+			COUNT(*) AS goods,
+			MAX(priority) AS max_priority,
+			COUNT(*) - COUNT(DISTINCT priority) AS duplicates,
+			MAX(priority) - COUNT(DISTINCT priority) AS gaps
+		FROM %s
+		WHERE $1 = 0 OR project_id = $1
+		GROUP BY project_id
+		HAVING COUNT(*) <> COUNT(DISTINCT priority) OR MAX(priority) <> COUNT(*) OR MIN(priority) <> 1
+		ORDER BY project_id
+	`, tableName)
+
+	rows, err := r.DB.Query(ctx, query, projectId)
+	if err != nil {
+		log.Error("failed to check priorities", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]model.PriorityReport, 0, 10)
+	for rows.Next() {
+		var report model.PriorityReport
+		if err := rows.Scan(
+			&report.ProjectID,
+			&report.Goods,
+			&report.MaxPriority,
+			&report.Duplicates,
+			&report.Gaps,
+		); err != nil {
+			log.Error("failed to scan row", "error", err)
+			return nil, err
+		}
+		list = append(list, report)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error("error while iterating over rows", "error", err)
+		return nil, err
+	}
+
+	log.Info("priorities checked", "inconsistentProjects", len(list))
+	return list, nil
+}
+
+// RepairPriorities renumbers the goods of the reported projects 1..N
+func (r *goodsRepo) RepairPriorities(ctx context.Context, projectId int) ([]model.ProductPriority, error) {
+	op := "goods repository: repairing priorities"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func RepairPriorities", "projectId", projectId)
+
+	reports, err := r.CheckPriorities(ctx, projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	ctxRollback, cancel := context.WithTimeout(context.Background(), rollbackTimer)
+	defer cancel()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctxRollback)
+
+	changed := make([]model.ProductPriority, 0, 10)
+	for _, report := range reports {
+		if err := lockProject(ctx, tx, report.ProjectID); err != nil {
+			log.Error("failed to lock project", "projectId", report.ProjectID, "error", err)
+			return nil, err
+		}
+
+		projectChanged, err := renumberPriorities(ctx, tx, report.ProjectID)
+		if err != nil {
+			log.Error("failed to renumber priorities", "projectId", report.ProjectID, "error", err)
+			return nil, err
+		}
+
+		if len(projectChanged) > 0 {
+			event := model.NewPrioritiesEvent(ctx, model.EventReordered, report.ProjectID, projectChanged)
+			if err := addToOutbox(ctx, tx, event); err != nil {
+				log.Error("failed to add event to outbox", "projectId", report.ProjectID, "error", err)
+				return nil, err
+			}
+		}
+
+		changed = append(changed, model.ProductPriorities(projectChanged)...)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", "error", err)
+		return nil, err
+	}
+
+	log.Info("priorities repaired", "projects", len(reports), "changed", len(changed))
+	return changed, nil
+}
+
+// renumberPriorities gives the goods of the project priorities 1..N
+func renumberPriorities(ctx context.Context, tx pgx.Tx, projectId int) ([]model.Product, error) {
+	query := fmt.Sprintf(`
+		UPDATE %s g
+		SET
+			priority = numbered.priority,
+			version = g.version + 1
+		FROM (
+			SELECT id, ROW_NUMBER() OVER (ORDER BY priority, id) AS priority
+			FROM %s
+			WHERE project_id = $1
+		) numbered
+		WHERE g.id = numbered.id AND g.priority <> numbered.priority
+		RETURNING g.id, g.project_id, g.name, g.description, g.priority, g.removed, g.created_at, g.version
+	`, tableName, tableName)
+
+	rows, err := tx.Query(ctx, query, projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanGoods(rows)
+}
+
+// scanGoods reads and closes the rows of goods
+func scanGoods(rows pgx.Rows) ([]model.Product, error) {
+	defer rows.Close()
+
+	list := make([]model.Product, 0, 10)
+	for rows.Next() {
+		var product model.Product
+		if err := rows.Scan(
+			&product.ID,
+			&product.ProjectID,
+			&product.Name,
+			&product.Description,
+			&product.Priority,
+			&product.Removed,
+			&product.CreatedAt,
+			&product.Version,
+		); err != nil {
+			return nil, err
+		}
+		list = append(list, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
@@ -0,0 +1,540 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hezzl/internal/model"
+	"hezzl/pkg/db/postgres"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testPostgresEnv is the connection string of the test Postgres
+const testPostgresEnv = "TEST_POSTGRES_PATH"
+
+// newTestPostgres migrates a schema of its own for the test and drops it when the test ends
+func newTestPostgres(t *testing.T) *postgres.PostgresDB {
+	t.Helper()
+
+	storagePath := os.Getenv(testPostgresEnv)
+	if storagePath == "" {
+		t.Skipf("%s is not set", testPostgresEnv)
+	}
+
+	admin, err := pgxpool.New(context.Background(), storagePath)
+	if err != nil {
+		t.Fatalf("failed to connect to postgres: %s", err)
+	}
+	t.Cleanup(admin.Close)
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(context.Background(), "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("failed to create schema: %s", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Errorf("failed to drop schema: %s", err)
+		}
+	})
+
+	u, err := url.Parse(storagePath)
+	if err != nil {
+		t.Fatalf("failed to parse %s: %s", testPostgresEnv, err)
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+
+	migrateDb, err := migrate.New("file://../../migrations/postgres", u.String())
+	if err != nil {
+		t.Fatalf("failed to prepare migrations: %s", err)
+	}
+	if err := migrateDb.Up(); err != nil {
+		t.Fatalf("failed to migrate: %s", err)
+	}
+	migrateDb.Close()
+
+	db, err := postgres.New(u.String())
+	if err != nil {
+		t.Fatalf("failed to connect to postgres: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func newTestGoodsRepo(t *testing.T) (*goodsRepo, *postgres.PostgresDB) {
+	t.Helper()
+
+	db := newTestPostgres(t)
+	return NewGoodsRepo(&GoodsRepoDeps{
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		PostgresDB: db,
+	}), db
+}
+
+// createTestGoods creates a project with goods named 1..count, the good named n has priority n
+func createTestGoods(t *testing.T, repo *goodsRepo, count int) (int, map[string]int) {
+	t.Helper()
+	ctx := context.Background()
+
+	var projectId int
+	if err := repo.DB.QueryRow(ctx, `INSERT INTO projects (name) VALUES ('test') RETURNING id`).Scan(&projectId); err != nil {
+		t.Fatalf("failed to create project: %s", err)
+	}
+
+	ids := make(map[string]int, count)
+	for i := 1; i <= count; i++ {
+		product, err := repo.Create(ctx, model.ProductCreateRequest{Name: strconv.Itoa(i), ProjectID: projectId})
+		if err != nil {
+			t.Fatalf("failed to create good: %s", err)
+		}
+		ids[product.Name] = product.ID
+	}
+
+	return projectId, ids
+}
+
+// goodsByPriority returns the names of the goods by priority ascending
+func goodsByPriority(t *testing.T, db *postgres.PostgresDB, projectId int) []string {
+	t.Helper()
+
+	rows, err := db.DB.Query(context.Background(), `SELECT name FROM goods WHERE project_id = $1 ORDER BY priority`, projectId)
+	if err != nil {
+		t.Fatalf("failed to list goods: %s", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("failed to scan good: %s", err)
+		}
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func TestGoodsRepoReprioritizy(t *testing.T) {
+	repo, db := newTestGoodsRepo(t)
+
+	staleVersion := 100
+
+	tests := []struct {
+		name    string
+		good    string
+		request func(ids map[string]int) model.ProductReprioritizyRequest
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "swap",
+			good: "1",
+			request: func(ids map[string]int) model.ProductReprioritizyRequest {
+				return model.ProductReprioritizyRequest{NewPriority: 4}
+			},
+			want: []string{"4", "2", "3", "1", "5"},
+		},
+		{
+			name: "swap missing good",
+			good: "missing",
+			request: func(ids map[string]int) model.ProductReprioritizyRequest {
+				return model.ProductReprioritizyRequest{NewPriority: 4}
+			},
+			wantErr: model.ErrNotFound,
+		},
+		{
+			name: "swap stale version",
+			good: "1",
+			request: func(ids map[string]int) model.ProductReprioritizyRequest {
+				return model.ProductReprioritizyRequest{NewPriority: 4, Version: &staleVersion}
+			},
+			wantErr: model.ErrConflict,
+		},
+		{
+			name: "move up",
+			good: "1",
+			request: func(ids map[string]int) model.ProductReprioritizyRequest {
+				return model.ProductReprioritizyRequest{NewPriority: 4, Mode: model.ReprioritizyModeMove}
+			},
+			want: []string{"2", "3", "4", "1", "5"},
+		},
+		{
+			name: "move down",
+			good: "5",
+			request: func(ids map[string]int) model.ProductReprioritizyRequest {
+				return model.ProductReprioritizyRequest{NewPriority: 2, Mode: model.ReprioritizyModeMove}
+			},
+			want: []string{"1", "5", "2", "3", "4"},
+		},
+		{
+			name: "before anchor moving down",
+			good: "5",
+			request: func(ids map[string]int) model.ProductReprioritizyRequest {
+				return model.ProductReprioritizyRequest{Before: ptr(ids["2"])}
+			},
+			want: []string{"1", "2", "5", "3", "4"},
+		},
+		{
+			name: "before anchor moving up",
+			good: "1",
+			request: func(ids map[string]int) model.ProductReprioritizyRequest {
+				return model.ProductReprioritizyRequest{Before: ptr(ids["3"])}
+			},
+			want: []string{"2", "3", "1", "4", "5"},
+		},
+		{
+			name: "after anchor moving up",
+			good: "1",
+			request: func(ids map[string]int) model.ProductReprioritizyRequest {
+				return model.ProductReprioritizyRequest{After: ptr(ids["4"])}
+			},
+			want: []string{"2", "3", "1", "4", "5"},
+		},
+		{
+			name: "after anchor moving down",
+			good: "5",
+			request: func(ids map[string]int) model.ProductReprioritizyRequest {
+				return model.ProductReprioritizyRequest{After: ptr(ids["3"])}
+			},
+			want: []string{"1", "2", "5", "3", "4"},
+		},
+		{
+			name: "top",
+			good: "2",
+			request: func(ids map[string]int) model.ProductReprioritizyRequest {
+				return model.ProductReprioritizyRequest{Position: model.PositionTop}
+			},
+			want: []string{"1", "3", "4", "5", "2"},
+		},
+		{
+			name: "bottom",
+			good: "4",
+			request: func(ids map[string]int) model.ProductReprioritizyRequest {
+				return model.ProductReprioritizyRequest{Position: model.PositionBottom}
+			},
+			want: []string{"4", "1", "2", "3", "5"},
+		},
+		{
+			name: "move to current priority",
+			good: "3",
+			request: func(ids map[string]int) model.ProductReprioritizyRequest {
+				return model.ProductReprioritizyRequest{NewPriority: 3, Mode: model.ReprioritizyModeMove}
+			},
+			wantErr: model.ErrCurrentPriority,
+		},
+		{
+			name: "move above max priority",
+			good: "3",
+			request: func(ids map[string]int) model.ProductReprioritizyRequest {
+				return model.ProductReprioritizyRequest{NewPriority: 6, Mode: model.ReprioritizyModeMove}
+			},
+			wantErr: model.ErrMaxPriority,
+		},
+		{
+			name: "move stale version",
+			good: "3",
+			request: func(ids map[string]int) model.ProductReprioritizyRequest {
+				return model.ProductReprioritizyRequest{NewPriority: 1, Mode: model.ReprioritizyModeMove, Version: &staleVersion}
+			},
+			wantErr: model.ErrConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectId, ids := createTestGoods(t, repo, 5)
+
+			request := tt.request(ids)
+			request.ID = ids[tt.good]
+			request.ProjectID = projectId
+
+			result, err := repo.Reprioritizy(context.Background(), request)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Reprioritizy() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil {
+				checkPrioritiesEvent(t, db, projectId, model.EventReprioritized, result.Priorities)
+			}
+
+			want := tt.want
+			if tt.wantErr != nil {
+				want = []string{"1", "2", "3", "4", "5"}
+			}
+
+			if got := goodsByPriority(t, db, projectId); !slices.Equal(got, want) {
+				t.Errorf("goods by priority = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// checkPrioritiesEvent checks the goods of the last event of the type
+func checkPrioritiesEvent(t *testing.T, db *postgres.PostgresDB, projectId int, eventType string, priorities []model.ProductPriority) {
+	t.Helper()
+
+	var payload []byte
+	query := `SELECT payload FROM outbox WHERE project_id = $1 AND type = $2 ORDER BY id DESC LIMIT 1`
+	if err := db.DB.QueryRow(context.Background(), query, projectId, eventType).Scan(&payload); err != nil {
+		t.Fatalf("failed to get %s event: %s", eventType, err)
+	}
+
+	var event model.GoodsEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		t.Fatalf("failed to decode %s event: %s", eventType, err)
+	}
+
+	if got := model.ProductPriorities(event.Goods); !slices.Equal(got, priorities) {
+		t.Errorf("%s event priorities = %v, want %v", eventType, got, priorities)
+	}
+
+	for _, el := range event.Goods {
+		if el.Name == "" || el.Version < 2 {
+			t.Errorf("%s event carries an incomplete good %+v", eventType, el)
+		}
+	}
+}
+
+func TestGoodsRepoPurge(t *testing.T) {
+	repo, db := newTestGoodsRepo(t)
+
+	tests := []struct {
+		name       string
+		remove     []string
+		wantPurged int
+		want       []string
+	}{
+		{
+			name:       "nothing removed",
+			wantPurged: 0,
+			want:       []string{"1", "2", "3", "4", "5"},
+		},
+		{
+			name:       "goods in the middle",
+			remove:     []string{"2", "4"},
+			wantPurged: 2,
+			want:       []string{"1", "3", "5"},
+		},
+		{
+			name:       "every good",
+			remove:     []string{"1", "2", "3", "4", "5"},
+			wantPurged: 5,
+			want:       nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			projectId, ids := createTestGoods(t, repo, 5)
+
+			for _, name := range tt.remove {
+				if _, err := repo.Remove(ctx, model.ProductRemoveRequest{ID: ids[name], ProjectID: projectId}); err != nil {
+					t.Fatalf("failed to remove good: %s", err)
+				}
+			}
+
+			purged, err := repo.Purge(ctx, time.Now().Add(time.Hour*24), projectId)
+			if err != nil {
+				t.Fatalf("Purge() error = %v", err)
+			}
+
+			if len(purged) != tt.wantPurged {
+				t.Errorf("Purge() purged %d goods, want %d", len(purged), tt.wantPurged)
+			}
+
+			if got := goodsByPriority(t, db, projectId); !slices.Equal(got, tt.want) {
+				t.Errorf("goods by priority = %v, want %v", got, tt.want)
+			}
+
+			var maxPriority int
+			query := `SELECT COALESCE(MAX(priority), 0) FROM goods WHERE project_id = $1`
+			if err := db.DB.QueryRow(ctx, query, projectId).Scan(&maxPriority); err != nil {
+				t.Fatalf("failed to get max priority: %s", err)
+			}
+
+			if maxPriority != len(tt.want) {
+				t.Errorf("max priority = %d, want %d", maxPriority, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestGoodsRepoRestore(t *testing.T) {
+	repo, _ := newTestGoodsRepo(t)
+
+	tests := []struct {
+		name    string
+		remove  bool
+		version func(removed *model.Product) *int
+		wantErr error
+	}{
+		{
+			name:   "removed good",
+			remove: true,
+		},
+		{
+			name:   "removed good of the expected version",
+			remove: true,
+			version: func(removed *model.Product) *int {
+				return ptr(removed.Version)
+			},
+		},
+		{
+			name:   "removed good of another version",
+			remove: true,
+			version: func(removed *model.Product) *int {
+				return ptr(removed.Version - 1)
+			},
+			wantErr: model.ErrConflict,
+		},
+		{
+			name:    "good that is not removed",
+			wantErr: model.ErrNotRemoved,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			projectId, ids := createTestGoods(t, repo, 1)
+			data := model.ProductRestoreRequest{ID: ids["1"], ProjectID: projectId}
+
+			if tt.remove {
+				removed, err := repo.Remove(ctx, model.ProductRemoveRequest{ID: data.ID, ProjectID: projectId})
+				if err != nil {
+					t.Fatalf("failed to remove good: %s", err)
+				}
+				if tt.version != nil {
+					data.Version = tt.version(removed)
+				}
+			}
+
+			product, err := repo.Restore(ctx, data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Restore() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil && product.Removed {
+				t.Errorf("Restore() removed = true, want false")
+			}
+		})
+	}
+
+	t.Run("missing good", func(t *testing.T) {
+		_, err := repo.Restore(context.Background(), model.ProductRestoreRequest{ID: -1, ProjectID: -1})
+		if !errors.Is(err, model.ErrNotFound) {
+			t.Errorf("Restore() error = %v, want %v", err, model.ErrNotFound)
+		}
+	})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestGoodsKeyset(t *testing.T) {
+	tests := []struct {
+		name      string
+		sort      []model.SortField
+		cursor    model.ProductCursor
+		args      []any
+		wantWhere string
+		wantArgs  []any
+	}{
+		{
+			name:      "default sort",
+			sort:      model.DefaultSort,
+			cursor:    model.ProductCursor{Values: []string{"5"}, ID: 3},
+			wantWhere: "((priority < $1::text::integer) OR (priority = $1::text::integer AND id < $2::integer))",
+			wantArgs:  []any{"5", 3},
+		},
+		{
+			name:      "default sort backward",
+			sort:      model.DefaultSort,
+			cursor:    model.ProductCursor{Values: []string{"5"}, ID: 3, Backward: true},
+			wantWhere: "((priority > $1::text::integer) OR (priority = $1::text::integer AND id > $2::integer))",
+			wantArgs:  []any{"5", 3},
+		},
+		{
+			name:   "mixed directions after filter args",
+			sort:   []model.SortField{{Field: "name"}, {Field: "created_at", Desc: true}},
+			cursor: model.ProductCursor{Values: []string{"apple", "2025-01-02T03:04:05"}, ID: 9},
+			args:   []any{1},
+			wantWhere: "((name > $2::text::text)" +
+				" OR (name = $2::text::text AND created_at < $3::text::timestamp)" +
+				" OR (name = $2::text::text AND created_at = $3::text::timestamp AND id < $4::integer))",
+			wantArgs: []any{1, "apple", "2025-01-02T03:04:05", 9},
+		},
+		{
+			name:      "ascending tiebreaker",
+			sort:      []model.SortField{{Field: "removed"}},
+			cursor:    model.ProductCursor{Values: []string{"false"}, ID: 4},
+			wantWhere: "((removed > $1::text::boolean) OR (removed = $1::text::boolean AND id > $2::integer))",
+			wantArgs:  []any{"false", 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args := goodsKeyset(tt.sort, tt.cursor, tt.args)
+			if where != tt.wantWhere {
+				t.Errorf("goodsKeyset() where = %s, want %s", where, tt.wantWhere)
+			}
+
+			if !slices.Equal(args, tt.wantArgs) {
+				t.Errorf("goodsKeyset() args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestGoodsOrderBy(t *testing.T) {
+	tests := []struct {
+		name    string
+		sort    []model.SortField
+		reverse bool
+		want    string
+	}{
+		{
+			name: "default sort",
+			sort: model.DefaultSort,
+			want: "priority DESC, id DESC",
+		},
+		{
+			name: "mixed directions",
+			sort: []model.SortField{{Field: "name", Desc: true}, {Field: "created_at"}},
+			want: "name DESC, created_at ASC, id ASC",
+		},
+		{
+			name:    "reversed",
+			sort:    []model.SortField{{Field: "name", Desc: true}, {Field: "created_at"}},
+			reverse: true,
+			want:    "name ASC, created_at DESC, id DESC",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := goodsOrderBy(tt.sort, tt.reverse); got != tt.want {
+				t.Errorf("goodsOrderBy() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hezzl/internal/model"
+	"hezzl/pkg/db/redis"
+	"log/slog"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	idempotencyName = "idempotency:"
+)
+
+var (
+	// saveReserved replaces the reservation KEYS[1] = ARGV[1] with ARGV[2] for ARGV[3] milliseconds
+	saveReserved = goredis.NewScript(`
+		if redis.call("GET", KEYS[1]) == ARGV[1] then
+			return redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+		end
+		return false
+	`)
+
+	// releaseReserved deletes the reservation KEYS[1] = ARGV[1]
+	releaseReserved = goredis.NewScript(`
+		if redis.call("GET", KEYS[1]) == ARGV[1] then
+			return redis.call("DEL", KEYS[1])
+		end
+		return 0
+	`)
+)
+
+type idempotencyRepo struct {
+	log     *slog.Logger
+	ttl     time.Duration
+	lockTTL time.Duration
+	*redis.RedisDB
+}
+
+// IdempotencyRepoDeps of the idempotency keys
+type IdempotencyRepoDeps struct {
+	*slog.Logger
+	*redis.RedisDB
+	TTL     time.Duration
+	LockTTL time.Duration
+}
+
+func NewIdempotencyRepo(deps *IdempotencyRepoDeps) *idempotencyRepo {
+	return &idempotencyRepo{
+		log:     deps.Logger,
+		ttl:     deps.TTL,
+		lockTTL: deps.LockTTL,
+		RedisDB: deps.RedisDB,
+	}
+}
+
+// Reserve reserves the free key, otherwise returns the stored record
+func (r *idempotencyRepo) Reserve(ctx context.Context, key string, reservation model.IdempotencyRecord) (*model.IdempotencyRecord, error) {
+	op := "idempotency repository: reserving"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func Reserve", "key", key)
+
+	ctx, cancel := context.WithTimeout(ctx, methodTimer)
+	defer cancel()
+
+	jsonData, err := json.Marshal(reservation)
+	if err != nil {
+		log.Error("failed to marshal json", "error", err)
+		return nil, err
+	}
+
+	reserved, err := r.Client.SetNX(ctx, idempotencyName+key, jsonData, r.lockTTL).Result()
+	if err != nil {
+		log.Error("failed to reserve key", "error", err)
+		return nil, err
+	}
+
+	if reserved {
+		log.Info("successfully reserved")
+		return nil, nil
+	}
+
+	result, err := r.Client.Get(ctx, idempotencyName+key).Result()
+	if err != nil {
+		// the record has expired between the calls
+		if strings.Contains(err.Error(), "redis: nil") {
+			log.Warn("record expired, reserving again")
+			return r.Reserve(ctx, key, reservation)
+		}
+		log.Error("failed to get data from redis", "error", err)
+		return nil, err
+	}
+
+	var record model.IdempotencyRecord
+	if err := json.Unmarshal([]byte(result), &record); err != nil {
+		log.Error("failed to unmarshal data", "error", err)
+		return nil, err
+	}
+
+	log.Info("successfully retrieved")
+	return &record, nil
+}
+
+// Save stores the response under the key reserved by the request
+func (r *idempotencyRepo) Save(key string, reservation, record model.IdempotencyRecord) {
+	op := "idempotency repository: saving"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func Save", "key", key, "status", record.Status)
+
+	ctx, cancel := context.WithTimeout(context.Background(), methodTimer)
+	defer cancel()
+
+	reserved, err := json.Marshal(reservation)
+	if err != nil {
+		log.Error("failed to marshal json", "error", err)
+		return
+	}
+
+	jsonData, err := json.Marshal(record)
+	if err != nil {
+		log.Error("failed to marshal json", "error", err)
+		return
+	}
+
+	err = saveReserved.Run(ctx, r.Client, []string{idempotencyName + key}, reserved, jsonData, r.ttl.Milliseconds()).Err()
+	if errors.Is(err, goredis.Nil) {
+		log.Warn("key is no longer reserved by the request")
+		return
+	}
+	if err != nil {
+		log.Error("failed to save a record", "error", err)
+		return
+	}
+
+	log.Info("successfully saved")
+}
+
+// Release frees the key reserved by the request
+func (r *idempotencyRepo) Release(key string, reservation model.IdempotencyRecord) {
+	op := "idempotency repository: releasing"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func Release", "key", key)
+
+	ctx, cancel := context.WithTimeout(context.Background(), methodTimer)
+	defer cancel()
+
+	reserved, err := json.Marshal(reservation)
+	if err != nil {
+		log.Error("failed to marshal json", "error", err)
+		return
+	}
+
+	deleted, err := releaseReserved.Run(ctx, r.Client, []string{idempotencyName + key}, reserved).Int()
+	if err != nil {
+		log.Error("failed to delete key", "error", err)
+		return
+	}
+
+	if deleted == 0 {
+		log.Warn("key is no longer reserved by the request")
+		return
+	}
+
+	log.Info("successfully released")
+}
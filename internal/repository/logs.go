@@ -31,14 +31,23 @@ func NewLogsRepo(deps *LogsRepoDeps) *logsRepo {
 	}
 }
 
-func (r *logsRepo) Create(data *model.Product) {
-	op := "logs repository: creating"
+// CreateBatch writes the rows to the goods log as one insert
+func (r *logsRepo) CreateBatch(ctx context.Context, rows []model.GoodsLog) error {
+	op := "logs repository: creating batch"
 	log := r.log.With(slog.String("operation", op))
-	log.Debug("Call func Create", "data", data)
+	log.Debug("Call func CreateBatch", "count", len(rows))
 
-	ctx, cancel := context.WithTimeout(context.Background(), logTimer)
+	ctx, cancel := context.WithTimeout(ctx, logTimer)
 	defer cancel()
 
+	// the clickhouse driver sends the rows of the prepared insert on commit
+	tx, err := r.ClickhouseDB.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("failed to begin batch", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
 	query := fmt.Sprintf(`
 		INSERT INTO %s (
 			Id,
@@ -46,23 +55,70 @@ func (r *logsRepo) Create(data *model.Product) {
 			Name,
 			Description,
 			Priority,
-			Removed
-		) VALUES (?, ?, ?, ?, ?, ?)
+			Removed,
+			Version,
+			Event,
+			EventId,
+			OccurredAt,
+			Actor,
+			SchemaVersion,
+			Before,
+			Changes
+		)
 	`, logTableName)
 
-	if _, err := r.ClickhouseDB.DB.ExecContext(
-		ctx,
-		query,
-		data.ID,
-		data.ProjectID,
-		data.Name,
-		data.Description,
-		data.Priority,
-		data.Removed,
-	); err != nil {
-		log.Error("failed to insert into clickhouse", "error", err)
-		return
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		log.Error("failed to prepare batch", "error", err)
+		return err
+	}
+	defer stmt.Close()
+
+	for _, el := range rows {
+		if _, err := stmt.ExecContext(
+			ctx,
+			el.ID,
+			el.ProjectID,
+			el.Name,
+			el.Description,
+			el.Priority,
+			el.Removed,
+			el.Version,
+			el.Event,
+			el.EventID,
+			el.OccurredAt,
+			el.Actor,
+			el.SchemaVersion,
+			el.Before,
+			el.Changes,
+		); err != nil {
+			log.Error("failed to append row to batch", "error", err)
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error("failed to send batch to clickhouse", "error", err)
+		return err
+	}
+
+	log.Info("successfully created", "count", len(rows))
+	return nil
+}
+
+// Ping checks that the goods log is available
+func (r *logsRepo) Ping(ctx context.Context) error {
+	op := "logs repository: ping"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func Ping")
+
+	ctx, cancel := context.WithTimeout(ctx, logTimer)
+	defer cancel()
+
+	if err := r.ClickhouseDB.DB.PingContext(ctx); err != nil {
+		log.Error("failed to ping clickhouse", "error", err)
+		return err
 	}
 
-	log.Info("successfully created")
+	return nil
 }
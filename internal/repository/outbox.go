@@ -0,0 +1,308 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hezzl/internal/model"
+	"hezzl/pkg/db/postgres"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	outboxTableName = "outbox"
+
+	// a failed message is retried after outboxBackoffBase * 2^attempts
+	outboxBackoffBase = time.Second
+
+	// outboxRelayLock is the advisory lock key held by the relay
+	outboxRelayLock = 5
+)
+
+type outboxRepo struct {
+	log        *slog.Logger
+	maxBackoff time.Duration
+	*postgres.PostgresDB
+}
+
+// OutboxRepoDeps of the outbox
+type OutboxRepoDeps struct {
+	*slog.Logger
+	*postgres.PostgresDB
+	MaxBackoff time.Duration
+}
+
+func NewOutboxRepo(deps *OutboxRepoDeps) *outboxRepo {
+	return &outboxRepo{
+		log:        deps.Logger,
+		maxBackoff: deps.MaxBackoff,
+		PostgresDB: deps.PostgresDB,
+	}
+}
+
+// addToOutbox stores the events in the transaction of the change
+func addToOutbox(ctx context.Context, tx pgx.Tx, events ...*model.GoodsEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(events))
+	projectIds := make([]int, 0, len(events))
+	types := make([]string, 0, len(events))
+	payloads := make([]string, 0, len(events))
+
+	for _, el := range events {
+		payload, err := json.Marshal(el)
+		if err != nil {
+			return err
+		}
+
+		ids = append(ids, el.ID)
+		projectIds = append(projectIds, el.ProjectID)
+		types = append(types, el.Type)
+		payloads = append(payloads, string(payload))
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s (event_id, project_id, type, payload)
+		SELECT *
+		FROM unnest($1::uuid[], $2::int[], $3::text[], $4::jsonb[])
+	`, outboxTableName)
+
+	_, err := tx.Exec(ctx, query, ids, projectIds, types, payloads)
+	return err
+}
+
+// Relay hands the pending messages to publish and returns the number of sent ones
+func (r *outboxRepo) Relay(ctx context.Context, limit int, publish func(msg *model.OutboxMessage) error) (int, error) {
+	op := "outbox repository: relaying"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func Relay", "limit", limit)
+
+	ctxRollback, cancel := context.WithTimeout(context.Background(), rollbackTimer)
+	defer cancel()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return 0, err
+	}
+	defer tx.Rollback(ctxRollback)
+
+	var locked bool
+	if err := tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock($1)`, outboxRelayLock).Scan(&locked); err != nil {
+		log.Error("failed to lock outbox", "error", err)
+		return 0, err
+	}
+
+	if !locked {
+		log.Debug("outbox is relayed by another instance")
+		return 0, nil
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, event_id, project_id, type, payload, attempts
+		FROM %s pending
+		WHERE sent_at IS NULL
+			AND next_attempt_at <= CURRENT_TIMESTAMP
+			AND NOT EXISTS (
+				SELECT 1
+				FROM %s earlier
+				WHERE earlier.project_id = pending.project_id
+					AND earlier.sent_at IS NULL
+					AND earlier.id < pending.id
+					AND earlier.next_attempt_at > CURRENT_TIMESTAMP
+			)
+		ORDER BY id
+		LIMIT $1
+	`, outboxTableName, outboxTableName)
+
+	rows, err := tx.Query(ctx, query, limit)
+	if err != nil {
+		log.Error("failed to get pending messages", "error", err)
+		return 0, err
+	}
+
+	messages := make([]model.OutboxMessage, 0, limit)
+	for rows.Next() {
+		var msg model.OutboxMessage
+		if err := rows.Scan(
+			&msg.ID,
+			&msg.EventID,
+			&msg.ProjectID,
+			&msg.Type,
+			&msg.Payload,
+			&msg.Attempts,
+		); err != nil {
+			rows.Close()
+			log.Error("failed to scan row", "error", err)
+			return 0, err
+		}
+		messages = append(messages, msg)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		log.Error("error while iterating over rows", "error", err)
+		return 0, err
+	}
+
+	sent := make([]int64, 0, len(messages))
+	for i := range messages {
+		publishErr := publish(&messages[i])
+		if publishErr == nil {
+			sent = append(sent, messages[i].ID)
+			continue
+		}
+
+		log.Warn("failed to publish message", "eventId", messages[i].EventID, "attempts", messages[i].Attempts+1, "error", publishErr)
+
+		failQuery := fmt.Sprintf(`
+			UPDATE %s
+			SET
+				attempts = attempts + 1,
+				last_error = $2,
+				next_attempt_at = CURRENT_TIMESTAMP + $3::interval
+			WHERE id = $1
+		`, outboxTableName)
+
+		if _, err := tx.Exec(ctx, failQuery, messages[i].ID, publishErr.Error(), outboxBackoff(messages[i].Attempts, r.maxBackoff)); err != nil {
+			log.Error("failed to put off message", "error", err)
+			return 0, err
+		}
+		break
+	}
+
+	if len(sent) > 0 {
+		sentQuery := fmt.Sprintf(`
+			UPDATE %s
+			SET sent_at = CURRENT_TIMESTAMP
+			WHERE id = ANY($1)
+		`, outboxTableName)
+
+		if _, err := tx.Exec(ctx, sentQuery, sent); err != nil {
+			log.Error("failed to mark messages sent", "error", err)
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", "error", err)
+		return 0, err
+	}
+
+	log.Info("successfully relayed", "sent", len(sent), "pending", len(messages)-len(sent))
+	return len(sent), nil
+}
+
+// outboxBackoff is the delay before the next attempt to publish a message failed attempts times
+func outboxBackoff(attempts int, maxBackoff time.Duration) time.Duration {
+	backoff := outboxBackoffBase
+	for range attempts {
+		backoff *= 2
+		if backoff >= maxBackoff {
+			break
+		}
+	}
+
+	return min(backoff, maxBackoff)
+}
+
+// DeleteSent deletes the messages sent before the time. Returns the number of deleted messages
+func (r *outboxRepo) DeleteSent(ctx context.Context, sentBefore time.Time) (int, error) {
+	op := "outbox repository: deleting sent"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func DeleteSent", "sentBefore", sentBefore)
+
+	query := fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE sent_at < $1
+	`, outboxTableName)
+
+	tag, err := r.DB.Exec(ctx, query, sentBefore)
+	if err != nil {
+		log.Error("failed to delete sent messages", "error", err)
+		return 0, err
+	}
+
+	log.Info("successfully deleted", "deleted", tag.RowsAffected())
+	return int(tag.RowsAffected()), nil
+}
+
+// CountHistory returns the number of the outbox messages selected by the filter, sent or not
+func (r *outboxRepo) CountHistory(ctx context.Context, filter model.ReplayFilter) (int, error) {
+	op := "outbox repository: counting history"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func CountHistory", "filter", filter)
+
+	var count int
+
+	query := fmt.Sprintf(`
+		SELECT COUNT(*)
+		FROM %s
+		WHERE ($1 = 0 OR project_id = $1)
+			AND ($2::timestamp IS NULL OR created_at >= $2)
+			AND ($3::timestamp IS NULL OR created_at < $3)
+	`, outboxTableName)
+
+	if err := r.DB.QueryRow(ctx, query, filter.ProjectID, filter.From, filter.To).Scan(&count); err != nil {
+		log.Error("failed to count messages", "error", err)
+		return 0, err
+	}
+
+	log.Info("successfully counted", "count", count)
+	return count, nil
+}
+
+// History returns up to limit outbox messages with ids greater than afterId
+func (r *outboxRepo) History(ctx context.Context, filter model.ReplayFilter, afterId int64, limit int) ([]model.OutboxMessage, error) {
+	op := "outbox repository: history retrieval"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func History", "filter", filter, "afterId", afterId, "limit", limit)
+
+	query := fmt.Sprintf(`
+		SELECT id, event_id, project_id, type, payload, attempts
+		FROM %s
+		WHERE id > $1
+			AND ($2 = 0 OR project_id = $2)
+			AND ($3::timestamp IS NULL OR created_at >= $3)
+			AND ($4::timestamp IS NULL OR created_at < $4)
+		ORDER BY id
+		LIMIT $5
+	`, outboxTableName)
+
+	rows, err := r.DB.Query(ctx, query, afterId, filter.ProjectID, filter.From, filter.To, limit)
+	if err != nil {
+		log.Error("failed to get messages", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make([]model.OutboxMessage, 0, limit)
+	for rows.Next() {
+		var msg model.OutboxMessage
+		if err := rows.Scan(
+			&msg.ID,
+			&msg.EventID,
+			&msg.ProjectID,
+			&msg.Type,
+			&msg.Payload,
+			&msg.Attempts,
+		); err != nil {
+			log.Error("failed to scan row", "error", err)
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error("error while iterating over rows", "error", err)
+		return nil, err
+	}
+
+	log.Info("successful search", "count", len(messages))
+	return messages, nil
+}
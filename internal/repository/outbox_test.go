@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"hezzl/internal/model"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	tests := []struct {
+		name       string
+		attempts   int
+		maxBackoff time.Duration
+		want       time.Duration
+	}{
+		{
+			name:       "first failure",
+			attempts:   0,
+			maxBackoff: time.Minute * 5,
+			want:       time.Second,
+		},
+		{
+			name:       "doubles",
+			attempts:   3,
+			maxBackoff: time.Minute * 5,
+			want:       time.Second * 8,
+		},
+		{
+			name:       "capped",
+			attempts:   9,
+			maxBackoff: time.Minute * 5,
+			want:       time.Minute * 5,
+		},
+		{
+			name:       "many attempts",
+			attempts:   1000,
+			maxBackoff: time.Minute * 5,
+			want:       time.Minute * 5,
+		},
+		{
+			name:       "max below base",
+			attempts:   0,
+			maxBackoff: time.Millisecond * 500,
+			want:       time.Millisecond * 500,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := outboxBackoff(tt.attempts, tt.maxBackoff); got != tt.want {
+				t.Errorf("outboxBackoff(%d, %s) = %s, want %s", tt.attempts, tt.maxBackoff, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutboxRepoRelay(t *testing.T) {
+	tests := []struct {
+		name string
+		// projects are the projects of the events in the order they are stored
+		projects []int
+		// fail are the events failing in the first relay
+		fail []int
+		// want are the events published by each relay
+		want [3][]int
+	}{
+		{
+			name:     "no failures",
+			projects: []int{1, 1, 2},
+			want:     [3][]int{{0, 1, 2}, nil, nil},
+		},
+		{
+			name:     "failed first event",
+			projects: []int{1, 1, 2},
+			fail:     []int{0},
+			want:     [3][]int{nil, {2}, {0, 1}},
+		},
+		{
+			name:     "failed event in the middle",
+			projects: []int{1, 2, 1, 2},
+			fail:     []int{1},
+			want:     [3][]int{{0}, {2}, {1, 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := newTestPostgres(t)
+			repo := NewOutboxRepo(&OutboxRepoDeps{
+				Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+				PostgresDB: db,
+				MaxBackoff: time.Minute,
+			})
+
+			tx, err := db.DB.Begin(ctx)
+			if err != nil {
+				t.Fatalf("failed to begin transaction: %s", err)
+			}
+			events := make(map[string]int, len(tt.projects))
+			for i, projectId := range tt.projects {
+				event := model.NewGoodsEvent(ctx, model.EventCreated, nil, &model.Product{ID: i + 1, ProjectID: projectId})
+				events[event.ID] = i
+				if err := addToOutbox(ctx, tx, event); err != nil {
+					t.Fatalf("failed to add event to outbox: %s", err)
+				}
+			}
+			if err := tx.Commit(ctx); err != nil {
+				t.Fatalf("failed to commit: %s", err)
+			}
+
+			for i, want := range tt.want {
+				if i == 2 {
+					if _, err := db.DB.Exec(ctx, `UPDATE outbox SET next_attempt_at = CURRENT_TIMESTAMP`); err != nil {
+						t.Fatalf("failed to end backoff: %s", err)
+					}
+				}
+
+				var got []int
+				_, err := repo.Relay(ctx, 10, func(msg *model.OutboxMessage) error {
+					if i == 0 && slices.Contains(tt.fail, events[msg.EventID]) {
+						return errors.New("broker is down")
+					}
+					got = append(got, events[msg.EventID])
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("Relay() error = %v", err)
+				}
+
+				if !slices.Equal(got, want) {
+					t.Errorf("relay %d published %v, want %v", i+1, got, want)
+				}
+			}
+		})
+	}
+}
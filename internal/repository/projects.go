@@ -0,0 +1,341 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hezzl/internal/model"
+	"hezzl/pkg/db/postgres"
+	"log/slog"
+	"strings"
+)
+
+const (
+	projectsTableName = "projects"
+)
+
+type projectsRepo struct {
+	log *slog.Logger
+	*postgres.PostgresDB
+}
+
+type ProjectsRepoDeps struct {
+	*slog.Logger
+	*postgres.PostgresDB
+}
+
+func NewProjectsRepo(deps *ProjectsRepoDeps) *projectsRepo {
+	return &projectsRepo{
+		log:        deps.Logger,
+		PostgresDB: deps.PostgresDB,
+	}
+}
+
+func (r *projectsRepo) Create(ctx context.Context, data model.ProjectCreateRequest) (*model.Project, error) {
+	op := "projects repository: creating"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func Create", "data", data)
+
+	var project model.Project
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s (name)
+		VALUES ($1)
+		RETURNING id, name, created_at
+	`, projectsTableName)
+
+	if err := r.DB.QueryRow(ctx, query, data.Name).
+		Scan(
+			&project.ID,
+			&project.Name,
+			&project.CreatedAt,
+		); err != nil {
+		log.Error("failed to create record", "error", err)
+		return nil, err
+	}
+
+	log.Info("successfully created")
+	return &project, nil
+}
+
+func (r *projectsRepo) Update(ctx context.Context, data model.ProjectUpdateRequest) (*model.Project, error) {
+	op := "projects repository: updating"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func Update", "data", data)
+
+	var project model.Project
+
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET name = $2
+		WHERE id = $1
+		RETURNING id, name, created_at
+	`, projectsTableName)
+
+	err := r.DB.QueryRow(ctx, query, data.ID, data.Name).
+		Scan(
+			&project.ID,
+			&project.Name,
+			&project.CreatedAt,
+		)
+
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			log.Warn("record not found", "error", err)
+			return nil, model.ErrNotFound
+		}
+		log.Error("failed to update record", "error", err)
+		return nil, err
+	}
+
+	log.Info("successfully updated")
+	return &project, nil
+}
+
+// Remove deletes the project with its goods
+func (r *projectsRepo) Remove(ctx context.Context, id int) (*model.ProjectRemoveResponce, error) {
+	op := "projects repository: removing"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func Remove", "id", id)
+
+	ctxRollback, cancel := context.WithTimeout(context.Background(), rollbackTimer)
+	defer cancel()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctxRollback)
+
+	if err := lockProject(ctx, tx, id); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			log.Warn("record not found", "error", err)
+			return nil, err
+		}
+		log.Error("failed to lock project", "error", err)
+		return nil, err
+	}
+
+	goodsQuery := fmt.Sprintf(`
+		SELECT id, project_id, name, description, priority, removed, created_at, version
+		FROM %s
+		WHERE project_id = $1
+		ORDER BY priority DESC
+		FOR UPDATE
+	`, tableName)
+
+	rows, err := tx.Query(ctx, goodsQuery, id)
+	if err != nil {
+		log.Error("failed to get project goods", "error", err)
+		return nil, err
+	}
+
+	goods := make([]model.Product, 0, 10)
+	for rows.Next() {
+		var product model.Product
+		if err := rows.Scan(
+			&product.ID,
+			&product.ProjectID,
+			&product.Name,
+			&product.Description,
+			&product.Priority,
+			&product.Removed,
+			&product.CreatedAt,
+			&product.Version,
+		); err != nil {
+			rows.Close()
+			log.Error("failed to scan row", "error", err)
+			return nil, err
+		}
+		goods = append(goods, product)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		log.Error("error while iterating over rows", "error", err)
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE id = $1
+		RETURNING id
+	`, projectsTableName)
+
+	result := model.ProjectRemoveResponce{
+		Removed:      true,
+		RemovedGoods: len(goods),
+	}
+
+	if err := tx.QueryRow(ctx, query, id).Scan(&result.ID); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			log.Warn("record not found", "error", err)
+			return nil, model.ErrNotFound
+		}
+		log.Error("failed to remove record", "error", err)
+		return nil, err
+	}
+
+	// the goods are deleted together with the project, so they are purged rather than removed
+	events := make([]*model.GoodsEvent, 0, len(goods))
+	for i := range goods {
+		events = append(events, model.NewGoodsEvent(ctx, model.EventPurged, &goods[i], nil))
+	}
+
+	if err := addToOutbox(ctx, tx, events...); err != nil {
+		log.Error("failed to add events to outbox", "error", err)
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", "error", err)
+		return nil, err
+	}
+
+	log.Info("successfully removed", "removedGoods", len(goods))
+	return &result, nil
+}
+
+// CompactPriorities renumbers the goods of the project 1..N
+func (r *projectsRepo) CompactPriorities(ctx context.Context, id int) ([]model.ProductPriority, error) {
+	op := "projects repository: compacting priorities"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func CompactPriorities", "id", id)
+
+	ctxRollback, cancel := context.WithTimeout(context.Background(), rollbackTimer)
+	defer cancel()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctxRollback)
+
+	if err := lockProject(ctx, tx, id); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			log.Warn("record not found", "error", err)
+			return nil, err
+		}
+		log.Error("failed to lock project", "error", err)
+		return nil, err
+	}
+
+	changed, err := renumberPriorities(ctx, tx, id)
+	if err != nil {
+		log.Error("failed to renumber priorities", "error", err)
+		return nil, err
+	}
+
+	if len(changed) > 0 {
+		if err := addToOutbox(ctx, tx, model.NewPrioritiesEvent(ctx, model.EventReordered, id, changed)); err != nil {
+			log.Error("failed to add event to outbox", "error", err)
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", "error", err)
+		return nil, err
+	}
+
+	log.Info("successfully compacted", "changed", len(changed))
+	return model.ProductPriorities(changed), nil
+}
+
+func (r *projectsRepo) Get(ctx context.Context, id int) (*model.Project, error) {
+	op := "projects repository: retrieving"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func Get", "id", id)
+
+	var project model.Project
+
+	query := fmt.Sprintf(`
+		SELECT id, name, created_at
+		FROM %s
+		WHERE id = $1
+	`, projectsTableName)
+
+	err := r.DB.QueryRow(ctx, query, id).
+		Scan(
+			&project.ID,
+			&project.Name,
+			&project.CreatedAt,
+		)
+
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			log.Warn("record not found", "error", err)
+			return nil, model.ErrNotFound
+		}
+		log.Error("failed to get record", "error", err)
+		return nil, err
+	}
+
+	log.Info("successfully retrieved")
+	return &project, nil
+}
+
+func (r *projectsRepo) List(ctx context.Context, offset, limit int) (*model.ProjectListResponce, error) {
+	op := "projects repository: projects list retrieval"
+	log := r.log.With(slog.String("operation", op))
+	log.Debug("Call func List", "offset", offset, "limit", limit)
+
+	var result model.ProjectListResponce
+
+	metaQuery := fmt.Sprintf(`
+		SELECT
+			(SELECT COUNT(*) FROM %s) AS total,
+			$1::int AS "limit",
+			$2::int + 1 AS "offset";
+	`, projectsTableName)
+
+	if err := r.DB.QueryRow(ctx, metaQuery, limit, offset-1).
+		Scan(
+			&result.Meta.Total,
+			&result.Meta.Limit,
+			&result.Meta.Offset,
+		); err != nil {
+		log.Error("failed metaQuery", "error", err)
+		return nil, err
+	}
+
+	listQuery := fmt.Sprintf(`
+		SELECT id, name, created_at
+		FROM %s
+		ORDER BY id
+		LIMIT $1 OFFSET $2;
+	`, projectsTableName)
+
+	rows, err := r.DB.Query(ctx, listQuery, limit, offset-1)
+	if err != nil {
+		log.Error("failed to get projects list", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]model.Project, 0, 10)
+	for rows.Next() {
+		var project model.Project
+		if err := rows.Scan(
+			&project.ID,
+			&project.Name,
+			&project.CreatedAt,
+		); err != nil {
+			log.Error("failed to scan row", "error", err)
+			return nil, err
+		}
+		list = append(list, project)
+	}
+
+	result.Projects = list
+
+	if err := rows.Err(); err != nil {
+		log.Error("error while iterating over rows", "error", err)
+		return nil, err
+	}
+
+	log.Info("successful search")
+	return &result, nil
+}
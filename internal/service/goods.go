@@ -4,46 +4,56 @@ import (
 	"context"
 	"hezzl/internal/model"
 	"log/slog"
+	"time"
 )
 
+// purgeActor is the actor of the changes made by the purge job
+const purgeActor = "purge-job"
+
 type IGoodsRepo interface {
 	Create(ctx context.Context, data model.ProductCreateRequest) (*model.Product, error)
+	BulkCreate(ctx context.Context, projectId int, data []model.ProductCreateRequest) ([]model.Product, error)
 	Update(ctx context.Context, data model.ProductUpdateRequest) (*model.Product, error)
-	Remove(ctx context.Context, id, projectId int) (*model.ProductRemoveResponce, error)
-	List(ctx context.Context, offset, limit int) (*model.ProductListResponce, error)
+	Remove(ctx context.Context, data model.ProductRemoveRequest) (*model.Product, error)
+	BulkUpdate(ctx context.Context, data []model.ProductUpdateRequest, atomic bool) ([]model.ProductBulkOutcome, error)
+	BulkRemove(ctx context.Context, data []model.ProductRemoveRequest, atomic bool) ([]model.ProductBulkOutcome, error)
+	Restore(ctx context.Context, data model.ProductRestoreRequest) (*model.Product, error)
+	Purge(ctx context.Context, removedBefore time.Time, projectId int) ([]model.Product, error)
+	Get(ctx context.Context, id, projectId int) (*model.Product, error)
+	List(ctx context.Context, filter model.ProductListFilter) (*model.ProductListResponce, error)
 	Reprioritizy(ctx context.Context, data model.ProductReprioritizyRequest) (*model.ProductReprioritizyResponce, error)
+	CheckPriorities(ctx context.Context, projectId int) ([]model.PriorityReport, error)
+	RepairPriorities(ctx context.Context, projectId int) ([]model.ProductPriority, error)
 }
 
 type ICacheRepo interface {
-	AddGoodsList(data *model.ProductListResponce)
-	GetGoodsList(offset, limit int) *model.ProductListResponce
+	AddGoodsList(filter model.ProductListFilter, data *model.ProductListResponce)
+	GetGoodsList(filter model.ProductListFilter) *model.ProductListResponce
+	AddGood(data *model.Product)
+	GetGood(id, projectId int) *model.Product
 	InvalidateGoods()
 }
 
-type IEventManager interface {
-	SendToBroker(data *model.Product)
-}
-
 type Goods struct {
-	log   *slog.Logger
-	repo  IGoodsRepo
-	cache ICacheRepo
-	event IEventManager
+	log            *slog.Logger
+	repo           IGoodsRepo
+	cache          ICacheRepo
+	purgeRetention time.Duration
 }
 
 type GoodsDeps struct {
 	*slog.Logger
 	IGoodsRepo
 	ICacheRepo
-	IEventManager
+	PurgeRetention time.Duration
 }
 
 func NewGoods(deps *GoodsDeps) *Goods {
 	return &Goods{
-		log:   deps.Logger,
-		repo:  deps.IGoodsRepo,
-		cache: deps.ICacheRepo,
-		event: deps.IEventManager,
+		log:            deps.Logger,
+		repo:           deps.IGoodsRepo,
+		cache:          deps.ICacheRepo,
+		purgeRetention: deps.PurgeRetention,
 	}
 }
 
@@ -57,10 +67,28 @@ func (s *Goods) Create(ctx context.Context, data model.ProductCreateRequest) (*m
 		return nil, err
 	}
 
+	go s.cache.InvalidateGoods()
+
 	log.Info("successfully created")
 	return result, nil
 }
 
+func (s *Goods) BulkCreate(ctx context.Context, projectId int, data []model.ProductCreateRequest) ([]model.Product, error) {
+	op := "goods service: bulk creating"
+	log := s.log.With(slog.String("operation", op))
+	log.Debug("Call func BulkCreate", "projectId", projectId, "count", len(data))
+
+	result, err := s.repo.BulkCreate(ctx, projectId, data)
+	if err != nil {
+		return nil, err
+	}
+
+	go s.cache.InvalidateGoods()
+
+	log.Info("successfully created", "count", len(result))
+	return result, nil
+}
+
 func (s *Goods) Update(ctx context.Context, data model.ProductUpdateRequest) (*model.Product, error) {
 	op := "goods service: updating"
 	log := s.log.With(slog.String("operation", op))
@@ -72,49 +100,190 @@ func (s *Goods) Update(ctx context.Context, data model.ProductUpdateRequest) (*m
 	}
 
 	go s.cache.InvalidateGoods()
-	go s.event.SendToBroker(result)
 
 	log.Info("successfully updated")
 	return result, nil
 }
 
-func (s *Goods) Remove(ctx context.Context, id, projectId int) (*model.ProductRemoveResponce, error) {
+func (s *Goods) Remove(ctx context.Context, data model.ProductRemoveRequest) (*model.ProductRemoveResponce, error) {
 	op := "goods service: removing"
 	log := s.log.With(slog.String("operation", op))
-	log.Debug("Call func Remove", "id", id, "projectId", projectId)
+	log.Debug("Call func Remove", "data", data)
 
-	result, err := s.repo.Remove(ctx, id, projectId)
+	result, err := s.repo.Remove(ctx, data)
 	if err != nil {
 		return nil, err
 	}
 
 	go s.cache.InvalidateGoods()
-	go s.event.SendToBroker(&model.Product{
+
+	log.Info("successfully removed")
+	return &model.ProductRemoveResponce{
 		ID:        result.ID,
 		ProjectID: result.ProjectID,
 		Removed:   result.Removed,
-	})
+		Version:   result.Version,
+	}, nil
+}
 
-	log.Info("successfully removed")
+func (s *Goods) BulkUpdate(ctx context.Context, data []model.ProductUpdateRequest, atomic bool) ([]model.ProductBulkOutcome, error) {
+	op := "goods service: bulk updating"
+	log := s.log.With(slog.String("operation", op))
+	log.Debug("Call func BulkUpdate", "count", len(data), "atomic", atomic)
+
+	result, err := s.repo.BulkUpdate(ctx, data, atomic)
+	if err != nil {
+		return nil, err
+	}
+
+	s.afterBulk(result)
+
+	log.Info("bulk update finished")
+	return result, nil
+}
+
+func (s *Goods) BulkRemove(ctx context.Context, data []model.ProductRemoveRequest, atomic bool) ([]model.ProductBulkOutcome, error) {
+	op := "goods service: bulk removing"
+	log := s.log.With(slog.String("operation", op))
+	log.Debug("Call func BulkRemove", "count", len(data), "atomic", atomic)
+
+	result, err := s.repo.BulkRemove(ctx, data, atomic)
+	if err != nil {
+		return nil, err
+	}
+
+	s.afterBulk(result)
+
+	log.Info("bulk remove finished")
+	return result, nil
+}
+
+// afterBulk invalidates the cache once if any good has changed
+func (s *Goods) afterBulk(result []model.ProductBulkOutcome) {
+	for _, el := range result {
+		if el.Err == nil {
+			go s.cache.InvalidateGoods()
+			return
+		}
+	}
+}
+
+func (s *Goods) Restore(ctx context.Context, data model.ProductRestoreRequest) (*model.Product, error) {
+	op := "goods service: restoring"
+	log := s.log.With(slog.String("operation", op))
+	log.Debug("Call func Restore", "data", data)
+
+	result, err := s.repo.Restore(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	go s.cache.InvalidateGoods()
+
+	log.Info("successfully restored")
+	return result, nil
+}
+
+// Purge deletes the goods removed longer than the retention ago. A zero projectId purges every project
+func (s *Goods) Purge(ctx context.Context, projectId int) (*model.ProductPurgeResponce, error) {
+	op := "goods service: purging"
+	log := s.log.With(slog.String("operation", op))
+	log.Debug("Call func Purge", "projectId", projectId)
+
+	purged, err := s.repo.Purge(ctx, time.Now().Add(-s.purgeRetention), projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.ProductPurgeResponce{
+		Goods:  make([]model.ProductRemoveResponce, 0, len(purged)),
+		Purged: len(purged),
+	}
+
+	for _, el := range purged {
+		result.Goods = append(result.Goods, model.ProductRemoveResponce{
+			ID:        el.ID,
+			ProjectID: el.ProjectID,
+			Removed:   el.Removed,
+		})
+	}
+
+	if len(purged) > 0 {
+		go s.cache.InvalidateGoods()
+	}
+
+	log.Info("successfully purged", "purged", len(purged))
 	return result, nil
 }
 
-func (s *Goods) List(ctx context.Context, offset, limit int) (*model.ProductListResponce, error) {
+// RunPurge purges removed goods of every project each interval until ctx is done
+func (s *Goods) RunPurge(ctx context.Context, interval time.Duration) {
+	op := "goods service: purge job"
+	log := s.log.With(slog.String("operation", op))
+	log.Info("purge job started", "interval", interval, "retention", s.purgeRetention)
+
+	ctx = model.ContextWithActor(ctx, purgeActor)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("purge job stopped")
+			return
+		case <-ticker.C:
+			if _, err := s.Purge(ctx, 0); err != nil {
+				log.Error("failed to purge goods", "error", err)
+			}
+		}
+	}
+}
+
+// Get returns a single good. A removed good is reported as not found unless includeRemoved is set
+func (s *Goods) Get(ctx context.Context, id, projectId int, includeRemoved bool) (*model.Product, error) {
+	op := "goods service: retrieving"
+	log := s.log.With(slog.String("operation", op))
+	log.Debug("Call func Get", "id", id, "projectId", projectId, "includeRemoved", includeRemoved)
+
+	result := s.cache.GetGood(id, projectId)
+	if result != nil {
+		log.Debug("data was retrieved from the cache")
+	} else {
+		var err error
+		result, err = s.repo.Get(ctx, id, projectId)
+		if err != nil {
+			return nil, err
+		}
+
+		go s.cache.AddGood(result)
+	}
+
+	if result.Removed && !includeRemoved {
+		log.Warn("good is removed")
+		return nil, model.ErrNotFound
+	}
+
+	log.Info("successfully retrieved")
+	return result, nil
+}
+
+func (s *Goods) List(ctx context.Context, filter model.ProductListFilter) (*model.ProductListResponce, error) {
 	op := "goods service: goods list retrieval"
 	log := s.log.With(slog.String("operation", op))
-	log.Debug("Call func List", "offset", offset, "limit", limit)
+	log.Debug("Call func List", "filter", filter)
 
-	if cacheResult := s.cache.GetGoodsList(offset, limit); cacheResult != nil {
+	if cacheResult := s.cache.GetGoodsList(filter); cacheResult != nil {
 		log.Debug("data was retrieved from the cache")
 		return cacheResult, nil
 	}
 
-	result, err := s.repo.List(ctx, offset, limit)
+	result, err := s.repo.List(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	go s.cache.AddGoodsList(result)
+	go s.cache.AddGoodsList(filter, result)
 
 	log.Info("successful search")
 	return result, nil
@@ -131,16 +300,39 @@ func (s *Goods) Reprioritizy(ctx context.Context, data model.ProductReprioritizy
 	}
 
 	go s.cache.InvalidateGoods()
-	go func() {
-		for _, el := range result.Priorities {
-			s.event.SendToBroker(&model.Product{
-				ID:        el.ID,
-				ProjectID: el.ProjectID,
-				Priority:  el.Priority,
-			})
-		}
-	}()
 
 	log.Info("successfully reprioritized")
 	return result, nil
 }
+
+func (s *Goods) CheckPriorities(ctx context.Context, projectId int) (*model.PriorityCheckResponce, error) {
+	op := "goods service: checking priorities"
+	log := s.log.With(slog.String("operation", op))
+	log.Debug("Call func CheckPriorities", "projectId", projectId)
+
+	result, err := s.repo.CheckPriorities(ctx, projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("priorities checked")
+	return &model.PriorityCheckResponce{Projects: result}, nil
+}
+
+func (s *Goods) RepairPriorities(ctx context.Context, projectId int) (*model.ProductReprioritizyResponce, error) {
+	op := "goods service: repairing priorities"
+	log := s.log.With(slog.String("operation", op))
+	log.Debug("Call func RepairPriorities", "projectId", projectId)
+
+	result, err := s.repo.RepairPriorities(ctx, projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) > 0 {
+		go s.cache.InvalidateGoods()
+	}
+
+	log.Info("priorities repaired")
+	return &model.ProductReprioritizyResponce{Priorities: result}, nil
+}
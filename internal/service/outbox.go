@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"hezzl/internal/model"
+	"log/slog"
+	"time"
+)
+
+type IOutboxRepo interface {
+	Relay(ctx context.Context, limit int, publish func(msg *model.OutboxMessage) error) (int, error)
+	DeleteSent(ctx context.Context, sentBefore time.Time) (int, error)
+}
+
+type IOutboxPublisher interface {
+	Publish(msg *model.OutboxMessage) error
+}
+
+type Outbox struct {
+	log       *slog.Logger
+	repo      IOutboxRepo
+	publisher IOutboxPublisher
+	batchSize int
+	retention time.Duration
+}
+
+type OutboxDeps struct {
+	*slog.Logger
+	IOutboxRepo
+	IOutboxPublisher
+	BatchSize int
+	Retention time.Duration
+}
+
+func NewOutbox(deps *OutboxDeps) *Outbox {
+	return &Outbox{
+		log:       deps.Logger,
+		repo:      deps.IOutboxRepo,
+		publisher: deps.IOutboxPublisher,
+		batchSize: deps.BatchSize,
+		retention: deps.Retention,
+	}
+}
+
+// Relay publishes the pending events of the outbox
+func (s *Outbox) Relay(ctx context.Context) (int, error) {
+	op := "outbox service: relaying"
+	log := s.log.With(slog.String("operation", op))
+	log.Debug("Call func Relay")
+
+	var total int
+	for {
+		sent, err := s.repo.Relay(ctx, s.batchSize, s.publisher.Publish)
+		total += sent
+		if err != nil {
+			return total, err
+		}
+
+		if sent < s.batchSize || ctx.Err() != nil {
+			break
+		}
+	}
+
+	if total > 0 {
+		log.Info("successfully relayed", "sent", total)
+	}
+	return total, nil
+}
+
+// RunRelay relays the outbox each interval until ctx is done
+func (s *Outbox) RunRelay(ctx context.Context, interval time.Duration) {
+	op := "outbox service: relay job"
+	log := s.log.With(slog.String("operation", op))
+	log.Info("relay job started", "interval", interval, "batchSize", s.batchSize, "retention", s.retention)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("relay job stopped")
+			return
+		case <-ticker.C:
+			if _, err := s.Relay(ctx); err != nil {
+				log.Error("failed to relay outbox", "error", err)
+			}
+
+			if _, err := s.repo.DeleteSent(ctx, time.Now().Add(-s.retention)); err != nil {
+				log.Error("failed to delete sent events", "error", err)
+			}
+		}
+	}
+}
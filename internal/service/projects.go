@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"hezzl/internal/model"
+	"log/slog"
+)
+
+type IProjectsRepo interface {
+	Create(ctx context.Context, data model.ProjectCreateRequest) (*model.Project, error)
+	Update(ctx context.Context, data model.ProjectUpdateRequest) (*model.Project, error)
+	Remove(ctx context.Context, id int) (*model.ProjectRemoveResponce, error)
+	CompactPriorities(ctx context.Context, id int) ([]model.ProductPriority, error)
+	Get(ctx context.Context, id int) (*model.Project, error)
+	List(ctx context.Context, offset, limit int) (*model.ProjectListResponce, error)
+}
+
+type Projects struct {
+	log   *slog.Logger
+	repo  IProjectsRepo
+	cache ICacheRepo
+}
+
+type ProjectsDeps struct {
+	*slog.Logger
+	IProjectsRepo
+	ICacheRepo
+}
+
+func NewProjects(deps *ProjectsDeps) *Projects {
+	return &Projects{
+		log:   deps.Logger,
+		repo:  deps.IProjectsRepo,
+		cache: deps.ICacheRepo,
+	}
+}
+
+func (s *Projects) Create(ctx context.Context, data model.ProjectCreateRequest) (*model.Project, error) {
+	op := "projects service: creating"
+	log := s.log.With(slog.String("operation", op))
+	log.Debug("Call func Create", "data", data)
+
+	result, err := s.repo.Create(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("successfully created")
+	return result, nil
+}
+
+func (s *Projects) Update(ctx context.Context, data model.ProjectUpdateRequest) (*model.Project, error) {
+	op := "projects service: updating"
+	log := s.log.With(slog.String("operation", op))
+	log.Debug("Call func Update", "data", data)
+
+	result, err := s.repo.Update(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("successfully updated")
+	return result, nil
+}
+
+func (s *Projects) Remove(ctx context.Context, id int) (*model.ProjectRemoveResponce, error) {
+	op := "projects service: removing"
+	log := s.log.With(slog.String("operation", op))
+	log.Debug("Call func Remove", "id", id)
+
+	result, err := s.repo.Remove(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	go s.cache.InvalidateGoods()
+
+	log.Info("successfully removed")
+	return result, nil
+}
+
+// CompactPriorities renumbers the goods of the project 1..N
+func (s *Projects) CompactPriorities(ctx context.Context, id int) (*model.ProductReprioritizyResponce, error) {
+	op := "projects service: compacting priorities"
+	log := s.log.With(slog.String("operation", op))
+	log.Debug("Call func CompactPriorities", "id", id)
+
+	result, err := s.repo.CompactPriorities(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) > 0 {
+		go s.cache.InvalidateGoods()
+	}
+
+	log.Info("successfully compacted", "changed", len(result))
+	return &model.ProductReprioritizyResponce{Priorities: result}, nil
+}
+
+func (s *Projects) Get(ctx context.Context, id int) (*model.Project, error) {
+	op := "projects service: retrieving"
+	log := s.log.With(slog.String("operation", op))
+	log.Debug("Call func Get", "id", id)
+
+	result, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("successfully retrieved")
+	return result, nil
+}
+
+func (s *Projects) List(ctx context.Context, offset, limit int) (*model.ProjectListResponce, error) {
+	op := "projects service: projects list retrieval"
+	log := s.log.With(slog.String("operation", op))
+	log.Debug("Call func List", "offset", offset, "limit", limit)
+
+	result, err := s.repo.List(ctx, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("successful search")
+	return result, nil
+}
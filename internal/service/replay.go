@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hezzl/internal/model"
+	"log/slog"
+)
+
+type IReplayGoodsRepo interface {
+	CountForReplay(ctx context.Context, filter model.ReplayFilter) (int, error)
+	ListForReplay(ctx context.Context, filter model.ReplayFilter, afterId, limit int) ([]model.Product, error)
+}
+
+type IReplayOutboxRepo interface {
+	CountHistory(ctx context.Context, filter model.ReplayFilter) (int, error)
+	History(ctx context.Context, filter model.ReplayFilter, afterId int64, limit int) ([]model.OutboxMessage, error)
+}
+
+// IReplaySink writes the replayed events, to the broker or straight to the goods log
+type IReplaySink interface {
+	Write(ctx context.Context, msgs []model.OutboxMessage) error
+}
+
+// ReplaySinkFunc is a function used as IReplaySink
+type ReplaySinkFunc func(ctx context.Context, msgs []model.OutboxMessage) error
+
+func (f ReplaySinkFunc) Write(ctx context.Context, msgs []model.OutboxMessage) error {
+	return f(ctx, msgs)
+}
+
+type Replay struct {
+	log       *slog.Logger
+	goods     IReplayGoodsRepo
+	outbox    IReplayOutboxRepo
+	sink      IReplaySink
+	batchSize int
+	dryRun    bool
+}
+
+type ReplayDeps struct {
+	*slog.Logger
+	IReplayGoodsRepo
+	IReplayOutboxRepo
+	IReplaySink
+	BatchSize int
+	DryRun    bool
+}
+
+func NewReplay(deps *ReplayDeps) *Replay {
+	return &Replay{
+		log:       deps.Logger,
+		goods:     deps.IReplayGoodsRepo,
+		outbox:    deps.IReplayOutboxRepo,
+		sink:      deps.IReplaySink,
+		batchSize: deps.BatchSize,
+		dryRun:    deps.DryRun,
+	}
+}
+
+// Run replays the events of the source batch by batch
+func (s *Replay) Run(ctx context.Context, source string, filter model.ReplayFilter, progress func(model.ReplayProgress)) (*model.ReplayProgress, error) {
+	op := "replay service: running"
+	log := s.log.With(slog.String("operation", op))
+	log.Debug("Call func Run", "source", source, "filter", filter, "dryRun", s.dryRun)
+
+	var result *model.ReplayProgress
+	var err error
+
+	switch source {
+	case model.ReplaySourceState:
+		result, err = s.replayState(ctx, filter, progress)
+	case model.ReplaySourceOutbox:
+		result, err = s.replayOutbox(ctx, filter, progress)
+	default:
+		return &model.ReplayProgress{}, fmt.Errorf("%w: unknown replay source %q", model.ErrValidate, source)
+	}
+
+	if err != nil {
+		log.Error("replay stopped", "done", result.Done, "total", result.Total, "error", err)
+		return result, err
+	}
+
+	log.Info("successfully replayed", "done", result.Done, "dryRun", s.dryRun)
+	return result, nil
+}
+
+func (s *Replay) replayState(ctx context.Context, filter model.ReplayFilter, progress func(model.ReplayProgress)) (*model.ReplayProgress, error) {
+	var result model.ReplayProgress
+
+	total, err := s.goods.CountForReplay(ctx, filter)
+	if err != nil {
+		return &result, err
+	}
+	result.Total = total
+
+	var afterId int
+	for {
+		goods, err := s.goods.ListForReplay(ctx, filter, afterId, s.batchSize)
+		if err != nil {
+			return &result, err
+		}
+		if len(goods) == 0 {
+			break
+		}
+
+		msgs := make([]model.OutboxMessage, 0, len(goods))
+		for i := range goods {
+			event := model.NewSnapshotEvent(ctx, &goods[i])
+			payload, err := json.Marshal(event)
+			if err != nil {
+				return &result, err
+			}
+
+			msgs = append(msgs, model.OutboxMessage{
+				EventID:   event.ID,
+				Type:      event.Type,
+				Payload:   payload,
+				ProjectID: event.ProjectID,
+			})
+		}
+
+		if err := s.write(ctx, msgs); err != nil {
+			return &result, err
+		}
+
+		afterId = goods[len(goods)-1].ID
+		result.Done += len(goods)
+		progress(result)
+
+		if len(goods) < s.batchSize {
+			break
+		}
+	}
+
+	return &result, nil
+}
+
+func (s *Replay) replayOutbox(ctx context.Context, filter model.ReplayFilter, progress func(model.ReplayProgress)) (*model.ReplayProgress, error) {
+	var result model.ReplayProgress
+
+	total, err := s.outbox.CountHistory(ctx, filter)
+	if err != nil {
+		return &result, err
+	}
+	result.Total = total
+
+	var afterId int64
+	for {
+		msgs, err := s.outbox.History(ctx, filter, afterId, s.batchSize)
+		if err != nil {
+			return &result, err
+		}
+		if len(msgs) == 0 {
+			break
+		}
+
+		if err := s.write(ctx, msgs); err != nil {
+			return &result, err
+		}
+
+		afterId = msgs[len(msgs)-1].ID
+		result.Done += len(msgs)
+		progress(result)
+
+		if len(msgs) < s.batchSize {
+			break
+		}
+	}
+
+	return &result, nil
+}
+
+func (s *Replay) write(ctx context.Context, msgs []model.OutboxMessage) error {
+	if s.dryRun {
+		return nil
+	}
+
+	return s.sink.Write(ctx, msgs)
+}
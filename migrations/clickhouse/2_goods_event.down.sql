@@ -0,0 +1 @@
+ALTER TABLE goods DROP COLUMN IF EXISTS Event;
@@ -0,0 +1 @@
+ALTER TABLE goods ADD COLUMN IF NOT EXISTS Event String DEFAULT '' AFTER Removed;
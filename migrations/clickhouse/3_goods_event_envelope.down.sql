@@ -0,0 +1,8 @@
+ALTER TABLE goods
+    DROP COLUMN IF EXISTS Changes,
+    DROP COLUMN IF EXISTS Before,
+    DROP COLUMN IF EXISTS SchemaVersion,
+    DROP COLUMN IF EXISTS Actor,
+    DROP COLUMN IF EXISTS OccurredAt,
+    DROP COLUMN IF EXISTS EventId,
+    DROP COLUMN IF EXISTS Version;
\ No newline at end of file
@@ -0,0 +1,8 @@
+ALTER TABLE goods
+    ADD COLUMN IF NOT EXISTS Version UInt64 DEFAULT 0 AFTER Removed,
+    ADD COLUMN IF NOT EXISTS EventId String DEFAULT '' AFTER Event,
+    ADD COLUMN IF NOT EXISTS OccurredAt DateTime64(3, 'UTC') DEFAULT now64(3) AFTER EventId,
+    ADD COLUMN IF NOT EXISTS Actor String DEFAULT '' AFTER OccurredAt,
+    ADD COLUMN IF NOT EXISTS SchemaVersion UInt16 DEFAULT 0 AFTER Actor,
+    ADD COLUMN IF NOT EXISTS Before String DEFAULT '' AFTER SchemaVersion,
+    ADD COLUMN IF NOT EXISTS Changes String DEFAULT '' AFTER Before;
\ No newline at end of file
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS goods_merge_tree (
+    Id UInt64,
+    ProjectId UInt64,
+    Name String,
+    Description String,
+    Priority Int32,
+    Removed UInt8,
+    Version UInt64 DEFAULT 0,
+    Event String DEFAULT '',
+    EventId String DEFAULT '',
+    OccurredAt DateTime64(3, 'UTC') DEFAULT now64(3),
+    Actor String DEFAULT '',
+    SchemaVersion UInt16 DEFAULT 0,
+    Before String DEFAULT '',
+    Changes String DEFAULT '',
+    EventTime DateTime DEFAULT now(),
+    INDEX idx_ProjectId ProjectId TYPE minmax GRANULARITY 1,
+    INDEX idx_Name Name TYPE minmax GRANULARITY 1
+) ENGINE = MergeTree
+ORDER BY Id;
+
+INSERT INTO goods_merge_tree SELECT * FROM goods;
+
+RENAME TABLE goods TO goods_dedup, goods_merge_tree TO goods;
+
+DROP TABLE goods_dedup;
@@ -0,0 +1,46 @@
+-- the engine of a table can not be changed, so the goods log is copied into a new table.
+-- The rows written again for the same good and event are merged into one in the background
+CREATE TABLE IF NOT EXISTS goods_dedup (
+    Id UInt64,
+    ProjectId UInt64,
+    Name String,
+    Description String,
+    Priority Int32,
+    Removed UInt8,
+    Version UInt64 DEFAULT 0,
+    Event String DEFAULT '',
+    EventId String DEFAULT '',
+    OccurredAt DateTime64(3, 'UTC') DEFAULT now64(3),
+    Actor String DEFAULT '',
+    SchemaVersion UInt16 DEFAULT 0,
+    Before String DEFAULT '',
+    Changes String DEFAULT '',
+    EventTime DateTime DEFAULT now(),
+    INDEX idx_ProjectId ProjectId TYPE minmax GRANULARITY 1,
+    INDEX idx_Name Name TYPE minmax GRANULARITY 1
+) ENGINE = ReplacingMergeTree
+ORDER BY (Id, EventId);
+
+-- the rows logged before the event ids get their own ids, so they are not merged with each other
+INSERT INTO goods_dedup
+SELECT
+    Id,
+    ProjectId,
+    Name,
+    Description,
+    Priority,
+    Removed,
+    Version,
+    Event,
+    if(EventId = '', toString(generateUUIDv4()), EventId),
+    OccurredAt,
+    Actor,
+    SchemaVersion,
+    Before,
+    Changes,
+    EventTime
+FROM goods;
+
+RENAME TABLE goods TO goods_merge_tree, goods_dedup TO goods;
+
+DROP TABLE goods_merge_tree;
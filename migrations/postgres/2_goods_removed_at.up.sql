@@ -0,0 +1,5 @@
+ALTER TABLE goods ADD COLUMN IF NOT EXISTS removed_at TIMESTAMP;
+
+UPDATE goods SET removed_at = CURRENT_TIMESTAMP WHERE removed = true AND removed_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_goods_removed_at ON goods (removed_at) WHERE removed = true;
@@ -0,0 +1 @@
+ALTER TABLE goods DROP CONSTRAINT IF EXISTS goods_project_priority_unique;
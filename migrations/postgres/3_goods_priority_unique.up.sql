@@ -0,0 +1,13 @@
+-- renumber the goods of every project 1..N in the current order, so the constraint can be added
+UPDATE goods g
+SET priority = numbered.priority
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY project_id ORDER BY priority, id) AS priority
+    FROM goods
+) numbered
+WHERE g.id = numbered.id AND g.priority <> numbered.priority;
+
+-- deferrable, so updates that move several priorities at once are checked at the end of the statement
+ALTER TABLE goods
+    ADD CONSTRAINT goods_project_priority_unique UNIQUE (project_id, priority)
+    DEFERRABLE INITIALLY IMMEDIATE;
@@ -0,0 +1 @@
+ALTER TABLE goods ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_outbox_project_pending ON outbox (project_id, id) WHERE sent_at IS NULL;
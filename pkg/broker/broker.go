@@ -0,0 +1,38 @@
+package broker
+
+import (
+	"context"
+	"time"
+)
+
+// Message is a message published to the broker
+type Message struct {
+	Header  map[string]string
+	Subject string
+	ID      string
+	Data    []byte
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, msg *Message) error
+	Close() error
+}
+
+// Subscriber fetches the messages of its subjects
+type Subscriber interface {
+	// Fetch returns up to batch messages, waiting for the first one at most maxWait
+	Fetch(ctx context.Context, batch int, maxWait time.Duration) ([]Delivery, error)
+	// MaxDeliver is how many times a message is delivered at most, zero is unlimited
+	MaxDeliver() int
+}
+
+// Delivery is a fetched message
+type Delivery interface {
+	Subject() string
+	Data() []byte
+	// Delivered is how many times the message was delivered, this delivery included
+	Delivered() int
+	Ack() error
+	Nak(delay time.Duration) error
+	Term() error
+}
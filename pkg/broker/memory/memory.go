@@ -0,0 +1,230 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hezzl/pkg/broker"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrClosed    = errors.New("broker is closed")
+	ErrQueueFull = errors.New("queue is full")
+)
+
+// MemoryBroker is an in-process broker for local runs and tests
+type MemoryBroker struct {
+	mu              sync.Mutex
+	subscribers     []*subscriber
+	published       map[string]time.Time
+	done            chan struct{}
+	size            int
+	maxDeliver      int
+	duplicateWindow time.Duration
+}
+
+// New creates the broker
+func New(size, maxDeliver int, duplicateWindow time.Duration) *MemoryBroker {
+	log.Println("broker: in-memory broker started")
+
+	return &MemoryBroker{
+		published:       make(map[string]time.Time),
+		done:            make(chan struct{}),
+		size:            size,
+		maxDeliver:      maxDeliver,
+		duplicateWindow: duplicateWindow,
+	}
+}
+
+func (b *MemoryBroker) Close() error {
+	log.Println("broker: in-memory broker stop started")
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	select {
+	case <-b.done:
+		return errors.New("broker is already closed")
+	default:
+	}
+	close(b.done)
+
+	log.Println("broker: in-memory broker stop successful")
+	return nil
+}
+
+// Subscribe returns the subscriber of the subjects
+func (b *MemoryBroker) Subscribe(subject string) broker.Subscriber {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := &subscriber{
+		broker:  b,
+		subject: subject,
+		queue:   make(chan *delivery, b.size),
+	}
+	b.subscribers = append(b.subscribers, sub)
+
+	return sub
+}
+
+// Publish puts the message into the queues of the subscribers of its subject
+func (b *MemoryBroker) Publish(ctx context.Context, msg *broker.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	select {
+	case <-b.done:
+		return ErrClosed
+	default:
+	}
+
+	now := time.Now()
+	if msg.ID != "" {
+		for id, published := range b.published {
+			if now.Sub(published) > b.duplicateWindow {
+				delete(b.published, id)
+			}
+		}
+
+		if _, ok := b.published[msg.ID]; ok {
+			return nil
+		}
+	}
+
+	subscribers := make([]*subscriber, 0, len(b.subscribers))
+	for _, sub := range b.subscribers {
+		if !subjectMatches(sub.subject, msg.Subject) {
+			continue
+		}
+
+		if len(sub.queue) == cap(sub.queue) {
+			return fmt.Errorf("failed to publish to %q: %w", msg.Subject, ErrQueueFull)
+		}
+		subscribers = append(subscribers, sub)
+	}
+
+	for _, sub := range subscribers {
+		select {
+		case sub.queue <- &delivery{sub: sub, msg: msg}:
+		default:
+			// a redelivery has taken the room since the check
+			return fmt.Errorf("failed to publish to %q: %w", msg.Subject, ErrQueueFull)
+		}
+	}
+
+	if msg.ID != "" {
+		b.published[msg.ID] = now
+	}
+
+	return nil
+}
+
+// subjectMatches reports whether the subject is one of the subjects of the pattern
+func subjectMatches(pattern, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	subjectTokens := strings.Split(subject, ".")
+
+	for i, token := range patternTokens {
+		if token == ">" {
+			return len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) || (token != "*" && token != subjectTokens[i]) {
+			return false
+		}
+	}
+
+	return len(patternTokens) == len(subjectTokens)
+}
+
+type subscriber struct {
+	broker  *MemoryBroker
+	queue   chan *delivery
+	subject string
+}
+
+func (s *subscriber) Fetch(ctx context.Context, batch int, maxWait time.Duration) ([]broker.Delivery, error) {
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+
+	result := make([]broker.Delivery, 0, batch)
+
+	select {
+	case d := <-s.queue:
+		result = append(result, d.deliver())
+	case <-timer.C:
+		return result, nil
+	case <-ctx.Done():
+		return result, nil
+	case <-s.broker.done:
+		return nil, ErrClosed
+	}
+
+	for len(result) < batch {
+		select {
+		case d := <-s.queue:
+			result = append(result, d.deliver())
+		default:
+			return result, nil
+		}
+	}
+
+	return result, nil
+}
+
+func (s *subscriber) MaxDeliver() int {
+	return s.broker.maxDeliver
+}
+
+type delivery struct {
+	sub       *subscriber
+	msg       *broker.Message
+	delivered int
+}
+
+func (d *delivery) deliver() *delivery {
+	d.delivered++
+	return d
+}
+
+func (d *delivery) Subject() string {
+	return d.msg.Subject
+}
+
+func (d *delivery) Data() []byte {
+	return d.msg.Data
+}
+
+func (d *delivery) Delivered() int {
+	return d.delivered
+}
+
+func (d *delivery) Ack() error {
+	return nil
+}
+
+// Nak puts the message back into the queue after the delay
+func (d *delivery) Nak(delay time.Duration) error {
+	if d.sub.broker.maxDeliver > 0 && d.delivered >= d.sub.broker.maxDeliver {
+		return nil
+	}
+
+	time.AfterFunc(delay, func() {
+		select {
+		case <-d.sub.broker.done:
+		case d.sub.queue <- d:
+		default:
+			log.Printf("broker: redelivery to %q dropped: %s", d.msg.Subject, ErrQueueFull)
+		}
+	})
+
+	return nil
+}
+
+func (d *delivery) Term() error {
+	return nil
+}
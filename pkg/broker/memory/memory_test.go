@@ -0,0 +1,313 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"hezzl/pkg/broker"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSubjectMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		subject string
+		want    bool
+	}{
+		{
+			name:    "same subject",
+			pattern: "goods.1.created",
+			subject: "goods.1.created",
+			want:    true,
+		},
+		{
+			name:    "other subject",
+			pattern: "goods.1.created",
+			subject: "goods.1.removed",
+		},
+		{
+			name:    "token wildcard",
+			pattern: "goods.*.created",
+			subject: "goods.1.created",
+			want:    true,
+		},
+		{
+			name:    "token wildcard of a longer subject",
+			pattern: "goods.*",
+			subject: "goods.1.created",
+		},
+		{
+			name:    "tail wildcard",
+			pattern: "goods.>",
+			subject: "goods.1.created",
+			want:    true,
+		},
+		{
+			name:    "tail wildcard without tail",
+			pattern: "goods.>",
+			subject: "goods",
+		},
+		{
+			name:    "tail wildcard of other subject",
+			pattern: "goods.>",
+			subject: "dead.goods.1.created",
+		},
+		{
+			name:    "shorter subject",
+			pattern: "goods.1.created",
+			subject: "goods.1",
+		},
+		{
+			name:    "longer subject",
+			pattern: "goods.1",
+			subject: "goods.1.created",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := subjectMatches(tt.pattern, tt.subject); got != tt.want {
+				t.Errorf("subjectMatches(%q, %q) = %v, want %v", tt.pattern, tt.subject, got, tt.want)
+			}
+		})
+	}
+}
+
+// fetchAll fetches the messages already in the queue of the subscriber
+func fetchAll(t *testing.T, sub broker.Subscriber) []broker.Delivery {
+	t.Helper()
+
+	deliveries, err := sub.Fetch(context.Background(), 100, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	return deliveries
+}
+
+func TestMemoryBrokerPublish(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		window time.Duration
+		// publish publishes the messages, fetching the queue of the subscriber in between when needed
+		publish      func(t *testing.T, b *MemoryBroker, sub broker.Subscriber) []broker.Delivery
+		wantMessages []string
+	}{
+		{
+			name:   "other subject",
+			size:   10,
+			window: time.Minute,
+			publish: func(t *testing.T, b *MemoryBroker, sub broker.Subscriber) []broker.Delivery {
+				mustPublish(t, b, &broker.Message{Subject: "dead.goods.1.created", ID: "e1", Data: []byte("1")})
+				return fetchAll(t, sub)
+			},
+		},
+		{
+			name:   "duplicate within window",
+			size:   10,
+			window: time.Minute,
+			publish: func(t *testing.T, b *MemoryBroker, sub broker.Subscriber) []broker.Delivery {
+				mustPublish(t, b, &broker.Message{Subject: "goods.1.created", ID: "e1", Data: []byte("1")})
+				mustPublish(t, b, &broker.Message{Subject: "goods.1.created", ID: "e1", Data: []byte("1")})
+				return fetchAll(t, sub)
+			},
+			wantMessages: []string{"1"},
+		},
+		{
+			name:   "duplicate after window",
+			size:   10,
+			window: 10 * time.Millisecond,
+			publish: func(t *testing.T, b *MemoryBroker, sub broker.Subscriber) []broker.Delivery {
+				mustPublish(t, b, &broker.Message{Subject: "goods.1.created", ID: "e1", Data: []byte("1")})
+				time.Sleep(20 * time.Millisecond)
+				mustPublish(t, b, &broker.Message{Subject: "goods.1.created", ID: "e1", Data: []byte("1")})
+				return fetchAll(t, sub)
+			},
+			wantMessages: []string{"1", "1"},
+		},
+		{
+			name:   "messages without id",
+			size:   10,
+			window: time.Minute,
+			publish: func(t *testing.T, b *MemoryBroker, sub broker.Subscriber) []broker.Delivery {
+				mustPublish(t, b, &broker.Message{Subject: "goods.1.created", Data: []byte("1")})
+				mustPublish(t, b, &broker.Message{Subject: "goods.1.created", Data: []byte("1")})
+				return fetchAll(t, sub)
+			},
+			wantMessages: []string{"1", "1"},
+		},
+		{
+			name:   "full queue",
+			size:   1,
+			window: time.Minute,
+			publish: func(t *testing.T, b *MemoryBroker, sub broker.Subscriber) []broker.Delivery {
+				mustPublish(t, b, &broker.Message{Subject: "goods.1.created", ID: "e1", Data: []byte("1")})
+
+				err := b.Publish(context.Background(), &broker.Message{Subject: "goods.1.created", ID: "e2", Data: []byte("2")})
+				if !errors.Is(err, ErrQueueFull) {
+					t.Fatalf("Publish() error = %v, want %v", err, ErrQueueFull)
+				}
+
+				// the failed message is not a duplicate, so it is delivered when published again
+				deliveries := fetchAll(t, sub)
+				mustPublish(t, b, &broker.Message{Subject: "goods.1.created", ID: "e2", Data: []byte("2")})
+				return append(deliveries, fetchAll(t, sub)...)
+			},
+			wantMessages: []string{"1", "2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New(tt.size, 0, tt.window)
+			defer b.Close()
+			sub := b.Subscribe("goods.>")
+
+			deliveries := tt.publish(t, b, sub)
+
+			got := make([]string, 0, len(deliveries))
+			for _, el := range deliveries {
+				got = append(got, string(el.Data()))
+			}
+			if !slices.Equal(got, tt.wantMessages) {
+				t.Errorf("delivered = %v, want %v", got, tt.wantMessages)
+			}
+		})
+	}
+}
+
+func TestMemoryBrokerPublishFullQueueOfOneSubscriber(t *testing.T) {
+	b := New(1, 0, time.Minute)
+	defer b.Close()
+	all := b.Subscribe("goods.>")
+	created := b.Subscribe("goods.*.created")
+
+	mustPublish(t, b, &broker.Message{Subject: "goods.1.created", ID: "e1", Data: []byte("1")})
+	fetchAll(t, all)
+
+	// the queue of the created subscriber is full, so the message is put into none of the queues
+	err := b.Publish(context.Background(), &broker.Message{Subject: "goods.1.created", ID: "e2", Data: []byte("2")})
+	if !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Publish() error = %v, want %v", err, ErrQueueFull)
+	}
+	if got := fetchAll(t, all); len(got) != 0 {
+		t.Fatalf("delivered %d messages of the failed publish, want 0", len(got))
+	}
+
+	fetchAll(t, created)
+	mustPublish(t, b, &broker.Message{Subject: "goods.1.created", ID: "e2", Data: []byte("2")})
+
+	for name, sub := range map[string]broker.Subscriber{"all": all, "created": created} {
+		if got := fetchAll(t, sub); len(got) != 1 || string(got[0].Data()) != "2" {
+			t.Errorf("subscriber %s delivered %d messages, want the message once", name, len(got))
+		}
+	}
+}
+
+func TestMemoryBrokerNak(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxDeliver    int
+		naks          int
+		wantDelivered []int
+	}{
+		{
+			name:          "redelivered",
+			maxDeliver:    0,
+			naks:          2,
+			wantDelivered: []int{1, 2, 3},
+		},
+		{
+			name:          "redelivered up to max deliver",
+			maxDeliver:    2,
+			naks:          2,
+			wantDelivered: []int{1, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New(10, tt.maxDeliver, time.Minute)
+			defer b.Close()
+			sub := b.Subscribe("goods.>")
+
+			mustPublish(t, b, &broker.Message{Subject: "goods.1.created", ID: "e1", Data: []byte("1")})
+
+			var got []int
+			for range tt.naks + 1 {
+				deliveries, err := sub.Fetch(context.Background(), 1, 50*time.Millisecond)
+				if err != nil {
+					t.Fatalf("Fetch() error = %v", err)
+				}
+				if len(deliveries) == 0 {
+					break
+				}
+
+				got = append(got, deliveries[0].Delivered())
+				if err := deliveries[0].Nak(0); err != nil {
+					t.Fatalf("Nak() error = %v", err)
+				}
+			}
+
+			if !slices.Equal(got, tt.wantDelivered) {
+				t.Errorf("deliveries = %v, want %v", got, tt.wantDelivered)
+			}
+		})
+	}
+}
+
+func TestMemoryBrokerNakFullQueue(t *testing.T) {
+	b := New(1, 0, time.Minute)
+	defer b.Close()
+	sub := b.Subscribe("goods.>")
+
+	mustPublish(t, b, &broker.Message{Subject: "goods.1.created", ID: "e1", Data: []byte("1")})
+	deliveries := fetchAll(t, sub)
+	mustPublish(t, b, &broker.Message{Subject: "goods.1.created", ID: "e2", Data: []byte("2")})
+
+	// the queue is full with the second message, so the redelivery of the first one is dropped
+	if err := deliveries[0].Nak(0); err != nil {
+		t.Fatalf("Nak() error = %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	var got []string
+	for _, el := range append(fetchAll(t, sub), fetchAll(t, sub)...) {
+		got = append(got, string(el.Data()))
+	}
+	if want := []string{"2"}; !slices.Equal(got, want) {
+		t.Errorf("delivered = %v, want %v", got, want)
+	}
+}
+
+func TestMemoryBrokerClosed(t *testing.T) {
+	b := New(10, 0, time.Minute)
+	sub := b.Subscribe("goods.>")
+
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if _, err := sub.Fetch(context.Background(), 1, time.Second); !errors.Is(err, ErrClosed) {
+		t.Errorf("Fetch() error = %v, want %v", err, ErrClosed)
+	}
+
+	err := b.Publish(context.Background(), &broker.Message{Subject: "goods.1.created", ID: "e1"})
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("Publish() error = %v, want %v", err, ErrClosed)
+	}
+
+	if err := b.Close(); err == nil {
+		t.Errorf("second Close() error = nil, want an error")
+	}
+}
+
+func mustPublish(t *testing.T, b *MemoryBroker, msg *broker.Message) {
+	t.Helper()
+
+	if err := b.Publish(context.Background(), msg); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hezzl/pkg/broker"
 	"log"
 	"time"
 
@@ -48,29 +49,100 @@ func (b *NatsBroker) Close() error {
 	return nil
 }
 
-func (b *NatsBroker) CreateStream(streamName, subject string) error {
-	cfg := &nats.StreamConfig{
-		Name:     streamName,
-		Subjects: []string{subject},
-		MaxMsgs:  1000,
+// Publish publishes the message to JetStream, the message id is the JetStream message id
+func (b *NatsBroker) Publish(ctx context.Context, msg *broker.Message) error {
+	natsMsg := nats.NewMsg(msg.Subject)
+	natsMsg.Data = msg.Data
+	for name, value := range msg.Header {
+		natsMsg.Header.Set(name, value)
 	}
 
-	if _, err := b.Js.AddStream(cfg); err != nil {
+	// without a deadline the publish waits for the ack the default time of the JetStream context
+	opts := make([]nats.PubOpt, 0, 2)
+	if _, ok := ctx.Deadline(); ok {
+		opts = append(opts, nats.Context(ctx))
+	}
+	if msg.ID != "" {
+		opts = append(opts, nats.MsgId(msg.ID))
+	}
+
+	if _, err := b.Js.PublishMsg(natsMsg, opts...); err != nil {
+		return fmt.Errorf("failed to publish to %q: %w", msg.Subject, err)
+	}
+
+	return nil
+}
+
+// StreamConfig is the configuration of a stream
+type StreamConfig struct {
+	Retention       string
+	Storage         string
+	MaxAge          time.Duration
+	MaxMsgs         int64
+	MaxBytes        int64
+	DuplicateWindow time.Duration
+	Replicas        int
+}
+
+// ConsumerConfig is the configuration of a durable pull consumer
+type ConsumerConfig struct {
+	AckWait       time.Duration
+	MaxDeliver    int
+	MaxAckPending int
+}
+
+// CreateStream creates the stream or updates the existing one
+func (b *NatsBroker) CreateStream(streamName, subject string, conf StreamConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	retention, err := retentionPolicy(conf.Retention)
+	if err != nil {
+		return fmt.Errorf("failed to create stream %q: %w", streamName, err)
+	}
+
+	storage, err := storageType(conf.Storage)
+	if err != nil {
 		return fmt.Errorf("failed to create stream %q: %w", streamName, err)
 	}
 
+	cfg := jetstream.StreamConfig{
+		Name:       streamName,
+		Subjects:   []string{subject},
+		Retention:  retention,
+		Storage:    storage,
+		MaxAge:     conf.MaxAge,
+		MaxMsgs:    conf.MaxMsgs,
+		MaxBytes:   conf.MaxBytes,
+		Duplicates: conf.DuplicateWindow,
+		Replicas:   conf.Replicas,
+	}
+
+	jsm, err := jetstream.New(b.Conn)
+	if err != nil {
+		return fmt.Errorf("failed to create JetStream manager: %w", err)
+	}
+
+	if _, err := jsm.CreateOrUpdateStream(ctx, cfg); err != nil {
+		return fmt.Errorf("failed to create or update stream %q: %w", streamName, err)
+	}
+
+	log.Printf("broker: stream %s is up to date", streamName)
 	return nil
 }
 
-func (b *NatsBroker) EnsureConsumer(streamName, subject, consumerName string) (jetstream.Consumer, error) {
+// EnsureConsumer creates or updates the durable pull consumer of the stream
+func (b *NatsBroker) EnsureConsumer(streamName, subject, consumerName string, conf ConsumerConfig) (broker.Subscriber, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 
 	cfg := jetstream.ConsumerConfig{
-		Durable:      consumerName,
-		AckPolicy:    jetstream.AckExplicitPolicy,
-		MaxDeliver:   10,
-		ReplayPolicy: jetstream.ReplayInstantPolicy,
+		Durable:       consumerName,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       conf.AckWait,
+		MaxDeliver:    conf.MaxDeliver,
+		MaxAckPending: conf.MaxAckPending,
+		ReplayPolicy:  jetstream.ReplayInstantPolicy,
 	}
 
 	jsm, err := jetstream.New(b.Conn)
@@ -78,10 +150,34 @@ func (b *NatsBroker) EnsureConsumer(streamName, subject, consumerName string) (j
 		return nil, fmt.Errorf("failed to create JetStream manager: %w", err)
 	}
 
-	consumer, err := jsm.CreateOrUpdateConsumer(ctx, b.NameMess, cfg)
+	consumer, err := jsm.CreateOrUpdateConsumer(ctx, streamName, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to add consumer %q to stream %q: %w", consumerName, streamName, err)
 	}
 
-	return consumer, nil
+	return &subscriber{consumer: consumer}, nil
+}
+
+func retentionPolicy(retention string) (jetstream.RetentionPolicy, error) {
+	switch retention {
+	case "", "limits":
+		return jetstream.LimitsPolicy, nil
+	case "workqueue":
+		return jetstream.WorkQueuePolicy, nil
+	case "interest":
+		return jetstream.InterestPolicy, nil
+	default:
+		return 0, fmt.Errorf("unknown retention %q", retention)
+	}
+}
+
+func storageType(storage string) (jetstream.StorageType, error) {
+	switch storage {
+	case "", "file":
+		return jetstream.FileStorage, nil
+	case "memory":
+		return jetstream.MemoryStorage, nil
+	default:
+		return 0, fmt.Errorf("unknown storage %q", storage)
+	}
 }
@@ -0,0 +1,71 @@
+package nats
+
+import (
+	"context"
+	"hezzl/pkg/broker"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// subscriber is a JetStream pull consumer
+type subscriber struct {
+	consumer jetstream.Consumer
+}
+
+func (s *subscriber) Fetch(ctx context.Context, batch int, maxWait time.Duration) ([]broker.Delivery, error) {
+	msgs, err := s.consumer.Fetch(batch, jetstream.FetchMaxWait(maxWait))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]broker.Delivery, 0, batch)
+	for msg := range msgs.Messages() {
+		result = append(result, newDelivery(msg))
+	}
+
+	return result, msgs.Error()
+}
+
+func (s *subscriber) MaxDeliver() int {
+	return s.consumer.CachedInfo().Config.MaxDeliver
+}
+
+type delivery struct {
+	msg       jetstream.Msg
+	delivered int
+}
+
+func newDelivery(msg jetstream.Msg) *delivery {
+	// the metadata is only missing on messages not delivered by a consumer
+	delivered := 1
+	if meta, err := msg.Metadata(); err == nil {
+		delivered = int(meta.NumDelivered)
+	}
+
+	return &delivery{msg: msg, delivered: delivered}
+}
+
+func (d *delivery) Subject() string {
+	return d.msg.Subject()
+}
+
+func (d *delivery) Data() []byte {
+	return d.msg.Data()
+}
+
+func (d *delivery) Delivered() int {
+	return d.delivered
+}
+
+func (d *delivery) Ack() error {
+	return d.msg.Ack()
+}
+
+func (d *delivery) Nak(delay time.Duration) error {
+	return d.msg.NakWithDelay(delay)
+}
+
+func (d *delivery) Term() error {
+	return d.msg.Term()
+}